@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// What happens when an action fails, after any retries.
+const (
+	// OnErrorAbort skips the remaining actions. This is the default for
+	// commands.
+	OnErrorAbort = "abort"
+	// OnErrorContinue runs the remaining actions anyway. This is the default
+	// for triggers, timers and schedules.
+	OnErrorContinue = "continue"
+	// OnErrorRunActions runs the action's errorActions, then skips the
+	// remaining actions.
+	OnErrorRunActions = "runActions"
+)
+
+const defaultActionBackoff = time.Second
+
+// validateActions checks the actions' names and error handling.
+func validateActions(where string, actions []Action) error {
+	for _, a := range actions {
+		if !strings.Contains(a.Name, "::") {
+			return fmt.Errorf("%s: action '%s' must be in the form module::Action", where, a.Name)
+		}
+
+		switch a.OnError {
+		case "", OnErrorAbort, OnErrorContinue:
+		case OnErrorRunActions:
+			if len(a.ErrorActions) == 0 {
+				return fmt.Errorf("%s: action '%s' has onError runActions but no errorActions", where, a.Name)
+			}
+		default:
+			return fmt.Errorf("%s: action '%s' onError must be abort, continue or runActions", where, a.Name)
+		}
+
+		if err := validateActions(where, a.ErrorActions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare renders the action's args for cmd and fills in the ones mapped
+// from the command's args.
+func (a Action) prepare(cmd Params) Action {
+	a.Args = renderArgs(a.Args, cmd)
+	for i, argName := range a.UserArgMap {
+		if len(cmd.CommandArgs) >= i+1 {
+			a.Args[argName] = cmd.CommandArgs[i]
+		}
+	}
+	return a
+}
+
+// runActions runs the actions in order. A failed action is handled by its
+// onError, or by onError if it doesn't set one. The first error is returned
+// even if the remaining actions ran.
+func runActions(ctx context.Context, actions []Action, cmd Params, onError string) error {
+	var firstErr error
+	for _, a := range actions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		a = a.prepare(cmd)
+		err := runAction(ctx, a, cmd)
+		if err == nil {
+			continue
+		}
+
+		if a.ErrorMessage != "" {
+			reportActionError(ctx, a, cmd, err)
+		}
+
+		policy := a.OnError
+		if policy == "" {
+			policy = onError
+		}
+
+		switch policy {
+		case OnErrorContinue:
+			if firstErr == nil {
+				firstErr = err
+			}
+		case OnErrorRunActions:
+			// Failures are logged as they happen, the action's error is the
+			// one worth returning
+			runActions(ctx, a.ErrorActions, cmd, OnErrorContinue)
+			return err
+		default:
+			return err
+		}
+	}
+
+	return firstErr
+}
+
+// runAction runs a single action, retrying it as configured. Unknown actions
+// are skipped.
+func runAction(ctx context.Context, a Action, cmd Params) error {
+	f, ok := lookupAction(a.Name)
+	if !ok {
+		Log.WithFields(paramsFields(cmd)).WithField("action", a.Name).Warn("Skipping unknown action")
+		return nil
+	}
+	if err := moduleError(strings.SplitN(a.Name, "::", 2)[0]); err != nil {
+		return err
+	}
+
+	backoff := time.Duration(a.Backoff)
+	if backoff <= 0 {
+		backoff = defaultActionBackoff
+	}
+
+	for attempt := uint(1); ; attempt++ {
+		err := attemptAction(ctx, f, a, cmd)
+		if err == nil || attempt > a.Retries || ctx.Err() != nil {
+			return err
+		}
+
+		Log.WithFields(paramsFields(cmd)).WithFields(logrus.Fields{
+			"action":  a.Name,
+			"attempt": attempt,
+			"backoff": backoff.String(),
+		}).Warn("Retrying action")
+
+		if Sleep(ctx, backoff) != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func attemptAction(ctx context.Context, f ActionFunc, a Action, cmd Params) error {
+	if a.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(a.Timeout))
+		defer cancel()
+	}
+	return callAction(ctx, f, a, cmd)
+}
+
+// reportActionError says the action's errorMessage in chat. The message can
+// use {{error}} and {{action}} besides the usual template variables.
+func reportActionError(ctx context.Context, a Action, cmd Params, err error) {
+	msg := RenderTemplate(a.ErrorMessage, cmd, map[string]string{
+		"error":  err.Error(),
+		"action": a.Name,
+	})
+	if msg == "" {
+		return
+	}
+
+	if err := Reply(ctx, cmd, msg); err != nil {
+		Log.WithError(err).WithFields(paramsFields(cmd)).WithField("action", a.Name).Error("Failed to report action error")
+	}
+}
@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidateActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []Action
+		err     bool
+	}{
+		{name: "valid", actions: []Action{{Name: "test::Record", OnError: OnErrorContinue}}},
+		{name: "no module", actions: []Action{{Name: "Record"}}, err: true},
+		{name: "unknown onError", actions: []Action{{Name: "test::Record", OnError: "retry"}}, err: true},
+		{name: "runActions without errorActions", actions: []Action{{Name: "test::Record", OnError: OnErrorRunActions}}, err: true},
+		{name: "bad errorActions", actions: []Action{{Name: "test::Record", OnError: OnErrorRunActions, ErrorActions: []Action{{Name: "Record"}}}}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateActions("command 'test'", tt.actions)
+			if tt.err && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRunActionsOnError(t *testing.T) {
+	record := func(name string) Action {
+		return Action{Name: "test::Record", Args: map[string]string{"name": name}}
+	}
+	fail := func(onError string, errorActions ...Action) Action {
+		return Action{Name: "test::Fail", OnError: onError, ErrorActions: errorActions}
+	}
+
+	tests := []struct {
+		name    string
+		actions []Action
+		onError string
+		ran     []string
+	}{
+		{name: "abort by default", actions: []Action{record("a"), fail(""), record("b")}, onError: OnErrorAbort, ran: []string{"a"}},
+		{name: "continue by default", actions: []Action{record("a"), fail(""), record("b")}, onError: OnErrorContinue, ran: []string{"a", "b"}},
+		{name: "action continues", actions: []Action{fail(OnErrorContinue), record("b")}, onError: OnErrorAbort, ran: []string{"b"}},
+		{name: "action aborts", actions: []Action{fail(OnErrorAbort), record("b")}, onError: OnErrorContinue},
+		{name: "error actions", actions: []Action{fail(OnErrorRunActions, record("undo"), fail(""), record("undo more")), record("b")}, onError: OnErrorContinue, ran: []string{"undo", "undo more"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, `{}`)
+
+			err := runActions(context.Background(), tt.actions, Params{}, tt.onError)
+			if err == nil || err.Error() != "failed on purpose" {
+				t.Errorf("got %v, want the failed action's error", err)
+			}
+			if got := ranActions.take(); !sameItems(got, tt.ran) {
+				t.Errorf("ran %v, want %v", got, tt.ran)
+			}
+		})
+	}
+}
+
+func TestRunActionRetries(t *testing.T) {
+	var lock sync.Mutex
+	var attempts []time.Time
+	RegisterModule(Module{
+		Name: "flaky",
+		Actions: map[string]ActionFunc{
+			// Succeeds on the attempt given by its "succeed" arg
+			"Call": func(ctx context.Context, a Action, cmd Params) error {
+				lock.Lock()
+				defer lock.Unlock()
+
+				attempts = append(attempts, time.Now())
+				if fmt.Sprint(len(attempts)) == a.Args["succeed"] {
+					return nil
+				}
+				return errors.New("flaked")
+			},
+		},
+	})
+	forgetModule(t, "flaky")
+
+	tests := []struct {
+		name     string
+		retries  uint
+		succeed  string
+		attempts int
+		err      bool
+	}{
+		{name: "first time", retries: 2, succeed: "1", attempts: 1},
+		{name: "after retrying", retries: 2, succeed: "3", attempts: 3},
+		{name: "out of retries", retries: 2, succeed: "4", attempts: 3, err: true},
+		{name: "no retries", succeed: "2", attempts: 1, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, `{"enabledModules": ["flaky"]}`)
+			attempts = nil
+
+			a := Action{Name: "flaky::Call", Args: map[string]string{"succeed": tt.succeed}, Retries: tt.retries, Backoff: Duration(10 * time.Millisecond)}
+			err := runAction(context.Background(), a, Params{})
+			if tt.err && err == nil {
+				t.Error("expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Error(err)
+			}
+
+			if len(attempts) != tt.attempts {
+				t.Fatalf("attempted %d times, want %d", len(attempts), tt.attempts)
+			}
+			// The backoff doubles
+			for i := 1; i < len(attempts); i++ {
+				if wait := attempts[i].Sub(attempts[i-1]); wait < 10*time.Millisecond<<uint(i-1) {
+					t.Errorf("waited %s before attempt %d", wait, i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestRunActionTimeout(t *testing.T) {
+	useTestConfig(t, `{}`)
+
+	a := Action{Name: "test::Sleep", Args: map[string]string{"name": "slow", "duration": "1s"}, Timeout: Duration(10 * time.Millisecond)}
+	if err := runAction(context.Background(), a, Params{}); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := ranActions.take(); len(got) != 0 {
+		t.Errorf("ran %v", got)
+	}
+}
+
+func TestActionErrorMessage(t *testing.T) {
+	useTestConfig(t, `{}`)
+
+	a := Action{Name: "test::Fail", ErrorMessage: "{{action}} failed for {{user}}: {{error}}"}
+	runActions(context.Background(), []Action{a}, Params{Provider: "test", UserName: "erik"}, OnErrorAbort)
+
+	want := []string{"test::Fail failed for erik: failed on purpose"}
+	if got := replies.take(); !sameItems(got, want) {
+		t.Errorf("replied %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// AuditConfig sets how long audit entries are kept, 30 days by default.
+type AuditConfig struct {
+	Retention Duration `json:"retention"`
+}
+
+const defaultAuditRetention = 30 * 24 * time.Hour
+
+func (c AuditConfig) retention() time.Duration {
+	if c.Retention > 0 {
+		return time.Duration(c.Retention)
+	}
+	return defaultAuditRetention
+}
+
+// AuditEntry records one command or trigger run. Command holds the trigger's
+// name for triggers.
+type AuditEntry struct {
+	ID       uint64        `json:"id"`
+	Time     time.Time     `json:"time"`
+	Kind     string        `json:"kind"`
+	Provider string        `json:"provider,omitempty"`
+	UserID   string        `json:"userID,omitempty"`
+	UserName string        `json:"userName,omitempty"`
+	Command  string        `json:"command"`
+	Args     []string      `json:"args,omitempty"`
+	Points   uint64        `json:"points,omitempty"`
+	Success  bool          `json:"success"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+const (
+	AuditCommand = "command"
+	AuditTrigger = "trigger"
+)
+
+// AuditFilter selects audit entries. Empty fields match everything, User
+// matches the user's id or name and Command the command or trigger name,
+// both ignoring case.
+type AuditFilter struct {
+	User    string
+	Command string
+	Since   time.Time
+	// Limit keeps only the newest matches, 0 keeps them all
+	Limit int
+}
+
+func (f *AuditFilter) matches(e *AuditEntry) bool {
+	if f.User != "" && !strings.EqualFold(f.User, e.UserID) && !strings.EqualFold(f.User, e.UserName) {
+		return false
+	}
+	if f.Command != "" && !strings.EqualFold(strings.TrimPrefix(f.Command, "!"), e.Command) {
+		return false
+	}
+	return e.Time.After(f.Since)
+}
+
+func auditKey(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
+
+func init() {
+	Subscribe(func(e CommandExecutedEvent) {
+		recordAudit(AuditCommand, e.Params.Command, e.Params, e.Points, e.Err, e.Duration)
+	})
+
+	Subscribe(func(e TriggerExecutedEvent) {
+		recordAudit(AuditTrigger, e.Name, e.Params, 0, e.Err, e.Duration)
+	})
+}
+
+func recordAudit(kind string, name string, cmd Params, points uint64, err error, duration time.Duration) {
+	// Nothing to write to, e.g. while validating a config
+	if db == nil {
+		return
+	}
+
+	e := AuditEntry{
+		Time:     time.Now().Add(-duration),
+		Kind:     kind,
+		Provider: cmd.Provider,
+		UserID:   cmd.UserID,
+		UserName: cmd.UserName,
+		Command:  name,
+		Args:     cmd.CommandArgs,
+		Points:   points,
+		Success:  err == nil,
+		Duration: duration,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	if err := AddAuditEntry(e); err != nil {
+		Log.WithError(err).WithFields(paramsFields(cmd)).Error("Failed to record audit entry")
+	}
+}
+
+// AddAuditEntry stores e with the next id.
+func AddAuditEntry(e AuditEntry) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(AUDIT_BUCKET)
+
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		e.ID = id
+
+		j, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return b.Put(auditKey(e.ID), j)
+	})
+}
+
+// SearchAudit returns the entries matching f, oldest first.
+func SearchAudit(f AuditFilter) ([]AuditEntry, error) {
+	var entries []AuditEntry
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(AUDIT_BUCKET).Cursor()
+
+		// Newest first, so the limit keeps the newest
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var e AuditEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if !f.matches(&e) {
+				continue
+			}
+
+			entries = append(entries, e)
+			if f.Limit > 0 && len(entries) >= f.Limit {
+				break
+			}
+		}
+		return nil
+	})
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, err
+}
+
+// PruneAudit deletes the entries older than the configured retention and
+// returns how many were deleted.
+func PruneAudit() (int, error) {
+	before := time.Now().Add(-currentConfig().Audit.retention())
+
+	var keys [][]byte
+	err := db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(AUDIT_BUCKET)
+
+		// Entries are stored in the order they finished, stop at the first
+		// one worth keeping
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var e AuditEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if !e.Time.Before(before) {
+				break
+			}
+			keys = append(keys, k)
+		}
+
+		// Deleting while iterating skips entries
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// pruneAuditPeriodically prunes the audit log now and then every hour.
+func pruneAuditPeriodically() {
+	prune := func() {
+		n, err := PruneAudit()
+		if err != nil {
+			Log.WithError(err).Error("Failed to prune audit log")
+			return
+		}
+		if n > 0 {
+			Log.WithField("deleted", n).Debug("Pruned audit log")
+		}
+	}
+
+	prune()
+	for range time.Tick(time.Hour) {
+		prune()
+	}
+}
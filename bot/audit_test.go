@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSearchAudit(t *testing.T) {
+	openTestDatabase(t)
+
+	now := time.Now()
+	entries := []AuditEntry{
+		{Time: now.Add(-3 * time.Hour), Kind: AuditCommand, UserID: "1", UserName: "Erik", Command: "hi"},
+		{Time: now.Add(-2 * time.Hour), Kind: AuditCommand, UserID: "2", UserName: "Aaron", Command: "hi"},
+		{Time: now.Add(-time.Hour), Kind: AuditTrigger, Command: "bot::Startup"},
+		{Time: now, Kind: AuditCommand, UserID: "1", UserName: "Erik", Command: "so"},
+	}
+	for _, e := range entries {
+		if err := AddAuditEntry(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []uint64
+	}{
+		{name: "everything", want: []uint64{1, 2, 3, 4}},
+		{name: "user name", filter: AuditFilter{User: "erik"}, want: []uint64{1, 4}},
+		{name: "user id", filter: AuditFilter{User: "2"}, want: []uint64{2}},
+		{name: "command", filter: AuditFilter{Command: "!HI"}, want: []uint64{1, 2}},
+		{name: "trigger", filter: AuditFilter{Command: "bot::Startup"}, want: []uint64{3}},
+		{name: "since", filter: AuditFilter{Since: now.Add(-90 * time.Minute)}, want: []uint64{3, 4}},
+		{name: "limit keeps the newest", filter: AuditFilter{Limit: 2}, want: []uint64{3, 4}},
+		{name: "no match", filter: AuditFilter{User: "bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SearchAudit(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []uint64
+			for _, e := range got {
+				ids = append(ids, e.ID)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("got %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneAudit(t *testing.T) {
+	useTestConfig(t, `{"audit": {"retention": "24h"}}`)
+	openTestDatabase(t)
+
+	now := time.Now()
+	for _, age := range []time.Duration{72 * time.Hour, 25 * time.Hour, time.Hour, 0} {
+		if err := AddAuditEntry(AuditEntry{Time: now.Add(-age), Command: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := PruneAudit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d, want 2", n)
+	}
+
+	left, err := SearchAudit(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0].ID != 3 {
+		t.Errorf("kept %v, want entries 3 and 4", left)
+	}
+}
+
+func TestAuditRecordsCommands(t *testing.T) {
+	useTestConfig(t, `{"commands": {
+		"hydrate": {"enabled": true, "offline": true, "points": 100, "actions": [{"name": "test::Record"}]},
+		"broken": {"enabled": true, "offline": true, "actions": [{"name": "test::Fail"}]}
+	}}`)
+	openTestDatabase(t)
+	setPoints(t, "1", 500)
+
+	ExecuteCommand(context.Background(), Params{Provider: "test", UserID: "1", UserName: "erik", Command: "hydrate", CommandArgs: []string{"now"}})
+	ExecuteCommand(context.Background(), Params{Provider: "test", UserID: "1", UserName: "erik", Command: "broken"})
+
+	got, err := SearchAudit(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d entries, want 2", len(got))
+	}
+
+	e := got[0]
+	if e.Kind != AuditCommand || e.Command != "hydrate" || e.UserName != "erik" || e.Points != 100 || !e.Success || !sameItems(e.Args, []string{"now"}) {
+		t.Errorf("got %+v for hydrate", e)
+	}
+	e = got[1]
+	if e.Command != "broken" || e.Success || e.Error != "failed on purpose" {
+		t.Errorf("got %+v for broken", e)
+	}
+}
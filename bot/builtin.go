@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -16,47 +17,35 @@ var builtinCommands map[string]CommandFunc = map[string]CommandFunc{
 	"sounds":   soundListCmd,
 	"so":       shoutoutCmd,
 	"counters": listCountersCmd,
+	"counter":  counterCmd,
+	"cancel":   cancelCmd,
+	"quote":    quoteCmd,
 	// "rickroll": rickrollCommand,
 }
 
-func TwitchSay(cmd Params, msg string) error {
-	args := map[string]string{
-		"channel": cmd.Channel,
-		"message": msg,
-	}
-	return ExecuteAction("twitch", "Say", args, cmd)
-}
-
-func helpCmd(cmd Params) error {
+func helpCmd(ctx context.Context, cmd Params) error {
 	if len(cmd.CommandArgs) > 0 {
 		cname := cmd.CommandArgs[0]
-		if c, ok := config.Commands[cname]; ok {
-			return TwitchSay(cmd, fmt.Sprintf("%s: %s", cname, c.Description))
+		if c, ok := lookupCommand(currentConfig(), cname); ok && c.Description != "" {
+			return Reply(ctx, cmd, fmt.Sprintf("%s: %s", cname, c.Description))
 		}
 
 		return nil
 	}
 
-	cmds := make([]string, 0)
-	for _, c := range config.Commands {
-		if c.Enabled {
-			cmds = append(cmds, c.Name)
-		}
-	}
-
-	return TwitchSay(cmd, strings.Join(cmds, ", "))
+	return Reply(ctx, cmd, strings.Join(commandNames(), ", "))
 }
 
-func userInfoCmd(cmd Params) error {
+func userInfoCmd(ctx context.Context, cmd Params) error {
 	u, err := GetUser(cmd.UserID)
 	if err != nil {
 		return err
 	}
 
-	return TwitchSay(cmd, fmt.Sprintf("%s: %d points", u.DisplayName, u.Points))
+	return Reply(ctx, cmd, fmt.Sprintf("%s: %d points", u.DisplayName, u.Points))
 }
 
-func givePointsCmd(cmd Params) error {
+func givePointsCmd(ctx context.Context, cmd Params) error {
 	if len(cmd.CommandArgs) != 2 {
 		return nil
 	}
@@ -91,7 +80,7 @@ func givePointsCmd(cmd Params) error {
 	return nil
 }
 
-func soundListCmd(cmd Params) error {
+func soundListCmd(ctx context.Context, cmd Params) error {
 	files, err := ioutil.ReadDir(MediaPath())
 	if err != nil {
 		return err
@@ -105,18 +94,62 @@ func soundListCmd(cmd Params) error {
 		}
 	}
 
-	return TwitchSay(cmd, "sounds: "+strings.Join(sounds, ", "))
+	return Reply(ctx, cmd, "sounds: "+strings.Join(sounds, ", "))
+}
+
+func listCountersCmd(ctx context.Context, cmd Params) error {
+	return Reply(ctx, cmd, "counters: "+strings.Join(ListCounters(), ", "))
+}
+
+// counterCmd manages counters for moderators:
+//
+//	!counter name            show a counter
+//	!counter set name 5      set a counter
+//	!counter reset name      set a counter back to zero
+func counterCmd(ctx context.Context, cmd Params) error {
+	if !cmd.IsModerator() {
+		return nil
+	}
+
+	args := cmd.CommandArgs
+	var current uint64
+	switch {
+	case len(args) == 1:
+		current = GetCounter(args[0])
+	case len(args) == 2 && args[0] == "reset":
+		current = ResetCounter(args[1])
+		args = args[1:]
+	case len(args) == 3 && args[0] == "set":
+		value, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return Reply(ctx, cmd, fmt.Sprintf("'%s' is not a valid counter value", args[2]))
+		}
+		current = SetCounter(args[1], value)
+		args = args[1:]
+	default:
+		return Reply(ctx, cmd, "usage: !counter <name>, !counter set <name> <value>, !counter reset <name>")
+	}
+
+	return Reply(ctx, cmd, fmt.Sprintf("%s counter is now: %d", args[0], current))
 }
 
-func listCountersCmd(cmd Params) error {
-	return TwitchSay(cmd, "counters: "+strings.Join(ListCounters(), ", "))
+// cancelCmd stops every running command and trigger, e.g. a strobe that
+// someone queued up for five minutes.
+func cancelCmd(ctx context.Context, cmd Params) error {
+	if !cmd.IsModerator() {
+		return nil
+	}
+
+	err := Reply(ctx, cmd, "Cancelling all running commands")
+	CancelAll()
+	return err
 }
 
 // TODO; Hit Twitch API and ensure user exists
-func shoutoutCmd(cmd Params) error {
+func shoutoutCmd(ctx context.Context, cmd Params) error {
 	if len(cmd.CommandArgs) > 0 {
 		user := cmd.CommandArgs[0]
-		return TwitchSay(cmd, fmt.Sprintf("Shoutout %s! Check out their channel, shower them with follows and subs: https://twitch.tv/%s", user, user))
+		return Reply(ctx, cmd, fmt.Sprintf("Shoutout %s! Check out their channel, shower them with follows and subs: https://twitch.tv/%s", user, user))
 	}
 
 	return fmt.Errorf("username is required")
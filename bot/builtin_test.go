@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterCommands(t *testing.T) {
+	useTestConfig(t, `{}`)
+	openTestDatabase(t)
+
+	mod := map[string]int{"moderator": 1}
+	// Run in order against the same database
+	steps := []struct {
+		command string
+		badges  map[string]int
+		reply   string
+	}{
+		{command: "deaths++", reply: "deaths counter is now: 1"},
+		{command: "deaths++", reply: "deaths counter is now: 2"},
+		{command: "deaths--"},
+		{command: "deaths--", badges: mod, reply: "deaths counter is now: 1"},
+		{command: "deaths--", badges: mod, reply: "deaths counter is now: 0"},
+		{command: "deaths--", badges: mod, reply: "deaths counter is now: 0"},
+		{command: "counter set deaths 5"},
+		{command: "counter set deaths 5", badges: mod, reply: "deaths counter is now: 5"},
+		{command: "counter deaths", badges: mod, reply: "deaths counter is now: 5"},
+		{command: "counter set deaths five", badges: mod, reply: "'five' is not a valid counter value"},
+		{command: "counter reset deaths", badges: mod, reply: "deaths counter is now: 0"},
+		{command: "counter", badges: mod, reply: "usage: !counter <name>, !counter set <name> <value>, !counter reset <name>"},
+		{command: "counters", reply: "counters: deaths"},
+	}
+
+	for _, s := range steps {
+		args := strings.Fields(s.command)
+		err := ExecuteCommand(context.Background(), Params{Provider: "test", UserID: "1", UserBadges: s.badges, Command: args[0], CommandArgs: args[1:]})
+		if err != nil {
+			t.Fatalf("%s: %s", s.command, err)
+		}
+
+		var want []string
+		if s.reply != "" {
+			want = []string{s.reply}
+		}
+		if got := replies.take(); !sameItems(got, want) {
+			t.Errorf("%s: replied %q, want %q", s.command, got, want)
+		}
+	}
+}
+
+func TestStreamCountersReset(t *testing.T) {
+	useTestConfig(t, `{"streamCounters": ["deaths"]}`)
+	openTestDatabase(t)
+
+	SetCounter("deaths", 3)
+	SetCounter("wins", 2)
+	Publish(StreamStartedEvent{})
+	WaitForCommands(time.Second)
+
+	if got := GetCounter("deaths"); got != 0 {
+		t.Errorf("deaths is %d, want 0", got)
+	}
+	if got := GetCounter("wins"); got != 2 {
+		t.Errorf("wins is %d, want 2", got)
+	}
+}
@@ -0,0 +1,215 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ChatUser identifies someone chatting. IDs must be unique across providers,
+// Twitch uses Twitch user ids and other providers prefix theirs with the
+// provider name, e.g. "irc:erik".
+type ChatUser struct {
+	ID          string
+	Name        string
+	DisplayName string
+	Color       string
+	Badges      map[string]int
+
+	// Guest is set for users the provider couldn't authenticate, such as IRC
+	// users not logged in to services. Anyone could be behind their ID, so
+	// they don't keep points.
+	Guest bool
+}
+
+// ChatMessage is a message received by a chat provider.
+type ChatMessage struct {
+	Provider string
+	Channel  string
+	User     ChatUser
+	Text     string
+
+	// MainChannel is set for messages in the channel the bot runs for, only
+	// those run commands and triggers.
+	MainChannel bool
+	// Ignored is set for users such as other bots, whose messages don't earn
+	// points or fire chat triggers.
+	Ignored bool
+}
+
+// ChatEvent is something other than a message happening in chat, such as a
+// raid. It fires the trigger "<provider>::<name>".
+type ChatEvent struct {
+	Provider string
+	Channel  string
+	User     ChatUser
+	Name     string
+	Payload  map[string]string
+}
+
+// ChatProvider connects the bot to a chat service. Providers are registered
+// by the module of the same name and only connected when it is enabled.
+type ChatProvider interface {
+	Name() string
+	// MainChannel is the channel the bot runs for on this provider.
+	MainChannel() string
+
+	// Connect connects to chat and blocks until ctx is cancelled or the
+	// connection fails for good.
+	Connect(ctx context.Context) error
+	Say(channel string, message string) error
+
+	OnMessage(func(ChatMessage))
+	OnEvent(func(ChatEvent))
+}
+
+var chatProviders []ChatProvider
+
+func RegisterChatProvider(p ChatProvider) {
+	chatProviders = append(chatProviders, p)
+}
+
+func getChatProvider(name string) ChatProvider {
+	for _, p := range chatProviders {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// defaultChatProvider is used for replies that didn't come from chat, such as
+// timers, and is the first enabled provider.
+func defaultChatProvider() ChatProvider {
+	c := currentConfig()
+	for _, p := range chatProviders {
+		if c.moduleEnabled(p.Name()) {
+			return p
+		}
+	}
+	return nil
+}
+
+// ConnectChatProviders connects every enabled chat provider and blocks until
+// they have all stopped. A provider's error is logged as soon as it stops,
+// the first one is returned once they all have.
+func ConnectChatProviders(ctx context.Context) error {
+	c := currentConfig()
+
+	errs := make(chan error)
+	count := 0
+	for _, p := range chatProviders {
+		if !c.moduleEnabled(p.Name()) {
+			continue
+		}
+
+		p.OnMessage(HandleChatMessage)
+		p.OnEvent(HandleChatEvent)
+
+		count++
+		go func(p ChatProvider) {
+			if err := p.Connect(ctx); err != nil {
+				errs <- fmt.Errorf("%s: %s", p.Name(), err)
+				return
+			}
+			errs <- nil
+		}(p)
+	}
+
+	if count == 0 {
+		return fmt.Errorf("No chat provider is enabled")
+	}
+
+	var first error
+	for i := 0; i < count; i++ {
+		err := <-errs
+		if err == nil {
+			continue
+		}
+
+		Log.WithError(err).Error("Chat provider stopped")
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// ParseCommand splits a chat message like "!so erik" into the lower cased
+// command name and its arguments. ok is false if the message isn't a command.
+func ParseCommand(text string) (name string, args []string, ok bool) {
+	if !strings.HasPrefix(text, "!") {
+		return "", nil, false
+	}
+
+	parts := strings.Fields(text[1:])
+	if len(parts) == 0 {
+		return "", nil, false
+	}
+	return strings.ToLower(parts[0]), parts[1:], true
+}
+
+// HandleChatMessage keeps track of the user, runs commands and publishes a
+// ChatMessageEvent for a message received by any provider.
+func HandleChatMessage(m ChatMessage) {
+	u, err := GetUser(m.User.ID)
+	if err != nil {
+		Log.WithError(err).WithFields(logrus.Fields{
+			"provider": m.Provider,
+			"user":     m.User.Name,
+		}).Error("Failed to look up user")
+		return
+	}
+
+	if err := u.updateFromChat(m.User); err != nil {
+		return
+	}
+
+	if m.MainChannel && !m.Ignored {
+		RecordChatMessage()
+	}
+
+	name, args, isCommand := ParseCommand(m.Text)
+	Publish(ChatMessageEvent{User: u, Message: m, Command: isCommand})
+
+	if !isCommand {
+		if m.Text != "" && !m.Ignored && !m.User.Guest {
+			u.GivePoints(10)
+		}
+		return
+	}
+
+	if m.MainChannel {
+		RunCommand(Params{
+			Provider:    m.Provider,
+			Channel:     m.Channel,
+			UserID:      m.User.ID,
+			UserName:    m.User.DisplayName,
+			UserBadges:  m.User.Badges,
+			Command:     name,
+			CommandArgs: args,
+		})
+	}
+}
+
+// HandleChatEvent publishes a UserNoticeEvent for a chat event.
+func HandleChatEvent(e ChatEvent) {
+	Publish(UserNoticeEvent{Event: e})
+}
+
+// Reply says msg in the channel cmd came from, through the chat provider it
+// came from. Anything that didn't come from chat, like a timer, goes to the
+// main channel of the default provider.
+func Reply(ctx context.Context, cmd Params, msg string) error {
+	if p := getChatProvider(cmd.Provider); p != nil {
+		return p.Say(cmd.Channel, msg)
+	}
+
+	p := defaultChatProvider()
+	if p == nil {
+		return fmt.Errorf("No chat provider to reply with")
+	}
+	return p.Say(p.MainChannel(), msg)
+}
@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text    string
+		name    string
+		args    []string
+		command bool
+	}{
+		{text: "!hi", name: "hi", command: true},
+		{text: "!SO  erik  now", name: "so", args: []string{"erik", "now"}, command: true},
+		{text: "!", command: false},
+		{text: "! hi", name: "hi", command: true},
+		{text: "hi !there", command: false},
+		{text: "", command: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			name, args, ok := ParseCommand(tt.text)
+			if ok != tt.command {
+				t.Fatalf("command %t, want %t", ok, tt.command)
+			}
+			if name != tt.name || !sameItems(args, tt.args) {
+				t.Errorf("got %q %q, want %q %q", name, args, tt.name, tt.args)
+			}
+		})
+	}
+}
+
+func TestReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		provider string
+		channel  string
+		err      bool
+	}{
+		{name: "from chat", config: `{}`, provider: "test", channel: "#other"},
+		{name: "from elsewhere", config: `{"enabledModules": ["test"]}`, channel: "main"},
+		{name: "no provider enabled", config: `{}`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, tt.config)
+
+			err := Reply(context.Background(), Params{Provider: tt.provider, Channel: "#other"}, "hello")
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{tt.channel}
+			if got := replyChannels.take(); !sameItems(got, want) {
+				t.Errorf("replied in %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHandleChatMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    ChatUser
+		text    string
+		points  uint64
+		replies []string
+	}{
+		{name: "chatting earns points", user: ChatUser{ID: "1", DisplayName: "erik"}, text: "hello", points: 2510},
+		{name: "guests don't", user: ChatUser{ID: "irc:guest:erik", DisplayName: "erik", Guest: true}, text: "hello"},
+		{name: "commands don't", user: ChatUser{ID: "1", DisplayName: "erik"}, text: "!me", points: 2500, replies: []string{"erik: 2500 points"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, `{}`)
+			openTestDatabase(t)
+
+			HandleChatMessage(ChatMessage{Provider: "test", Channel: "main", User: tt.user, Text: tt.text, MainChannel: true})
+			WaitForCommands(time.Second)
+
+			u, err := GetUser(tt.user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Points != tt.points {
+				t.Errorf("points %d, want %d", u.Points, tt.points)
+			}
+			if got := replies.take(); !sameItems(got, tt.replies) {
+				t.Errorf("replied %q, want %q", got, tt.replies)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChatTrigger runs actions when a chat message that isn't a command matches
+// a regex pattern or contains one of a list of keywords.
+type ChatTrigger struct {
+	Name           string   `json:"name"`
+	Pattern        string   `json:"pattern"`
+	Keywords       []string `json:"keywords"`
+	Command        string   `json:"command"`
+	Actions        []Action `json:"actions"`
+	Offline        bool     `json:"offline"`
+	GlobalCooldown Duration `json:"globalCooldown"`
+	UserCooldown   Duration `json:"userCooldown"`
+
+	regex *regexp.Regexp
+}
+
+func (t *ChatTrigger) compile() error {
+	if t.Pattern == "" && len(t.Keywords) == 0 {
+		return fmt.Errorf("Chat trigger '%s' needs a pattern or keywords", t.Name)
+	}
+	if t.Command == "" && len(t.Actions) == 0 {
+		return fmt.Errorf("Chat trigger '%s' needs a command or actions", t.Name)
+	}
+
+	pattern := t.Pattern
+	if pattern == "" {
+		words := make([]string, 0, len(t.Keywords))
+		for _, k := range t.Keywords {
+			words = append(words, regexp.QuoteMeta(k))
+		}
+		pattern = `(?i)\b(` + strings.Join(words, "|") + `)\b`
+	}
+
+	var err error
+	if t.regex, err = regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("Chat trigger '%s': %s", t.Name, err)
+	}
+	return nil
+}
+
+// payload returns the capture groups of the match by index ("0" is the whole
+// match) and by name for named groups.
+func (t *ChatTrigger) payload(message string) (map[string]string, bool) {
+	match := t.regex.FindStringSubmatch(message)
+	if match == nil {
+		return nil, false
+	}
+
+	payload := map[string]string{
+		"message": message,
+		"match":   match[0],
+	}
+	for i, name := range t.regex.SubexpNames() {
+		payload[strconv.Itoa(i)] = match[i]
+		if name != "" {
+			payload[name] = match[i]
+		}
+	}
+	return payload, true
+}
+
+// RunChatTriggers matches the message against the configured chat triggers
+// on its own goroutine.
+func RunChatTriggers(cmd Params, message string) {
+	runner.run(func(ctx context.Context) {
+		ExecuteChatTriggers(ctx, cmd, message)
+	})
+}
+
+// ExecuteChatTriggers runs every chat trigger that matches the message, in the
+// order they are configured. A trigger that fails is logged and doesn't stop
+// the rest.
+func ExecuteChatTriggers(ctx context.Context, cmd Params, message string) {
+	c := currentConfig()
+
+	for i := range c.ChatTriggers {
+		t := &c.ChatTriggers[i]
+		if !Status.Streaming() && !t.Offline {
+			continue
+		}
+
+		payload, ok := t.payload(message)
+		if !ok {
+			continue
+		}
+
+		key := "chat:" + t.Name
+		if t.Name == "" {
+			key = "chat:" + strconv.Itoa(i)
+		}
+		if !cmd.CanBypassCooldown() {
+			if cooldowns.acquire(key, cmd.UserID, time.Duration(t.GlobalCooldown), time.Duration(t.UserCooldown)) > 0 {
+				continue
+			}
+		}
+
+		p := cmd
+		p.Payload = payload
+		if err := t.run(ctx, c, p); err != nil {
+			Log.WithError(err).WithFields(paramsFields(cmd)).WithField("chatTrigger", t.Name).Error("Chat trigger failed")
+		}
+	}
+}
+
+func (t *ChatTrigger) run(ctx context.Context, c *Config, cmd Params) error {
+	if t.Command != "" {
+		command, ok := lookupCommand(c, t.Command)
+		if !ok {
+			return fmt.Errorf("Chat trigger '%s': command not found %s", t.Name, t.Command)
+		}
+		if !command.Enabled {
+			return nil
+		}
+
+		// Typing the keyword gets the user no further than typing the
+		// command would, and is recorded the same way
+		cmd.Command = t.Command
+		ran := false
+		err := recordCommand(cmd, func(points *uint64) error {
+			var err error
+			ran, err = runCommand(ctx, command, cmd, points)
+			return err
+		})
+		if err != nil || !ran {
+			return err
+		}
+	}
+
+	// The command has its own timeout
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout(0))
+	defer cancel()
+	return runTriggerActions(ctx, Trigger{Actions: t.Actions}, cmd)
+}
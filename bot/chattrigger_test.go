@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChatTriggerPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger ChatTrigger
+		message string
+		match   bool
+		payload map[string]string
+	}{
+		{
+			name:    "keyword",
+			trigger: ChatTrigger{Keywords: []string{"keyboard", "desk"}, Actions: []Action{{Name: "test::Record"}}},
+			message: "What Keyboard is that?",
+			match:   true,
+			payload: map[string]string{"message": "What Keyboard is that?", "match": "Keyboard", "0": "Keyboard", "1": "Keyboard"},
+		},
+		{
+			name:    "keyword is a whole word",
+			trigger: ChatTrigger{Keywords: []string{"desk"}, Actions: []Action{{Name: "test::Record"}}},
+			message: "desktop",
+		},
+		{
+			name:    "keyword is quoted",
+			trigger: ChatTrigger{Keywords: []string{"c++"}, Actions: []Action{{Name: "test::Record"}}},
+			message: "cxx",
+		},
+		{
+			name:    "pattern with named group",
+			trigger: ChatTrigger{Pattern: `(?P<count>\d+) points`, Actions: []Action{{Name: "test::Record"}}},
+			message: "only 50 points?",
+			match:   true,
+			payload: map[string]string{"message": "only 50 points?", "match": "50 points", "0": "50 points", "1": "50", "count": "50"},
+		},
+		{
+			name:    "pattern without match",
+			trigger: ChatTrigger{Pattern: `^!`, Actions: []Action{{Name: "test::Record"}}},
+			message: "hi!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.trigger.compile(); err != nil {
+				t.Fatal(err)
+			}
+
+			payload, ok := tt.trigger.payload(tt.message)
+			if ok != tt.match {
+				t.Fatalf("matched %t, want %t", ok, tt.match)
+			}
+			if tt.match && !sameMap(payload, tt.payload) {
+				t.Errorf("payload %v, want %v", payload, tt.payload)
+			}
+		})
+	}
+}
+
+func TestChatTriggerCompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger ChatTrigger
+	}{
+		{name: "no pattern or keywords", trigger: ChatTrigger{Command: "hi"}},
+		{name: "no command or actions", trigger: ChatTrigger{Keywords: []string{"hi"}}},
+		{name: "invalid pattern", trigger: ChatTrigger{Pattern: "(", Command: "hi"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.trigger.compile(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+const chatTriggerConfig = `{
+	"commands": {
+		"hi": {"enabled": true, "offline": true, "actions": [{"name": "test::Record", "args": {"name": "hi"}}]},
+		"secret": {"enabled": true, "offline": true, "restrictions": ["broadcaster"], "actions": [{"name": "test::Record", "args": {"name": "secret"}}]},
+		"off": {"enabled": false, "offline": true, "actions": [{"name": "test::Record", "args": {"name": "off"}}]}
+	},
+	"chatTriggers": [
+		{"name": "restricted", "keywords": ["password"], "command": "secret", "offline": true, "actions": [{"name": "test::Record", "args": {"name": "restricted trigger"}}]},
+		{"name": "broken", "keywords": ["hello"], "command": "missing", "offline": true},
+		{"name": "greeting", "keywords": ["hello"], "command": "hi", "offline": true, "actions": [{"name": "test::Record", "args": {"name": "greeting trigger"}}]},
+		{"name": "disabled", "keywords": ["bye"], "command": "off", "offline": true, "actions": [{"name": "test::Record", "args": {"name": "disabled trigger"}}]},
+		{"name": "online", "keywords": ["live"], "actions": [{"name": "test::Record", "args": {"name": "online trigger"}}]},
+		{"name": "points", "pattern": "(?P<count>\\d+) points", "offline": true, "actions": [{"name": "test::Record", "args": {"name": "{{payload.count}}"}}]}
+	]
+}`
+
+func TestExecuteChatTriggers(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		badges   map[string]int
+		ran      []string
+		executed []string
+	}{
+		{name: "no match", message: "nothing to see"},
+		{name: "refused command skips the actions", message: "what's the password?", executed: []string{"secret"}},
+		{name: "permitted command runs the actions", message: "what's the password?", badges: map[string]int{"broadcaster": 1}, ran: []string{"secret", "restricted trigger"}, executed: []string{"secret"}},
+		{name: "failed trigger doesn't stop the rest", message: "hello there", ran: []string{"hi", "greeting trigger"}, executed: []string{"hi"}},
+		{name: "disabled command", message: "bye"},
+		{name: "offline", message: "are you live?"},
+		{name: "payload", message: "50 points please", ran: []string{"50"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, chatTriggerConfig)
+
+			ExecuteChatTriggers(context.Background(), Params{UserID: "1", UserName: "erik", UserBadges: tt.badges}, tt.message)
+
+			if got := ranActions.take(); !sameItems(got, tt.ran) {
+				t.Errorf("ran %v, want %v", got, tt.ran)
+			}
+			if got := executedCommands.take(); !sameItems(got, tt.executed) {
+				t.Errorf("executed %v, want %v", got, tt.executed)
+			}
+		})
+	}
+}
+
+func TestChatTriggerCooldown(t *testing.T) {
+	useTestConfig(t, `{"chatTriggers": [{"name": "kb", "keywords": ["keyboard"], "offline": true, "userCooldown": "1m", "actions": [{"name": "test::Record", "args": {"name": "{{user}}"}}]}]}`)
+
+	for _, user := range []string{"erik", "erik", "aaron"} {
+		ExecuteChatTriggers(context.Background(), Params{UserID: user, UserName: user}, "nice keyboard")
+	}
+	ExecuteChatTriggers(context.Background(), Params{UserID: "mod", UserName: "mod", UserBadges: map[string]int{"moderator": 1}}, "keyboard")
+	ExecuteChatTriggers(context.Background(), Params{UserID: "mod", UserName: "mod", UserBadges: map[string]int{"moderator": 1}}, "keyboard")
+
+	want := []string{"erik", "aaron", "mod", "mod"}
+	if got := ranActions.take(); !sameItems(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
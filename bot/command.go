@@ -1,99 +1,81 @@
 package bot
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nicklaw5/helix"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
 )
 
-type ActionFunc func(Action, Params) error
-type CommandFunc func(Params) error
+type ActionFunc func(context.Context, Action, Params) error
+type CommandFunc func(context.Context, Params) error
 
 type ModuleInitFunc func(config json.RawMessage) error
 
+// Plugins register their modules when they start, which may be while
+// commands are running, so modules and registeredActions are only used under
+// registryLock.
+var registryLock sync.RWMutex
 var modules []Module
 var registeredActions map[string]ActionFunc
 
-var config Config
 var Status status
+
+// The Twitch API client, nil until InitTwitchAPI succeeds
+var helixLock sync.RWMutex
 var helixClient *helix.Client
 
-// GetHelixClient returns a pointer to the helix client
+// GetHelixClient returns a pointer to the helix client, or nil if the Twitch
+// API isn't available.
 func GetHelixClient() *helix.Client {
-	return helixClient
-}
-
-type Config struct {
-	Commands       map[string]*Command        `json:"commands"`
-	Triggers       map[string]Trigger         `json:"triggers"`
-	EnabledModules []string                   `json:"enabledModules"`
-	DatabasePath   string                     `json:"databasePath"`
-	WebPath        string                     `json:"webPath"`
-	MediaPath      string                     `json:"mediaPath"`
-	ModuleConfig   map[string]json.RawMessage `json:"moduleConfig"`
-}
-
-func WebPath() string {
-	if config.WebPath == "" {
-		path, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-		return filepath.Join(path, "web")
-	}
-
-	return config.WebPath
-}
-
-func MediaPath() string {
-	if config.MediaPath == "" {
-		path, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-		return filepath.Join(path, "media")
-	}
-
-	return config.MediaPath
-}
-
-func DatabasePath() string {
-	if config.DatabasePath == "" {
-		path, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-		return filepath.Join(path, "bot.db")
-	}
-
-	return config.DatabasePath
-}
-
-func IsModuleEnabled(m string) bool {
-	for _, mod := range config.EnabledModules {
-		if mod == m {
-			return true
-		}
-	}
-	return false
-}
+	helixLock.RLock()
+	defer helixLock.RUnlock()
 
-type status struct {
-	Streaming bool
-	Scene     string
+	return helixClient
 }
 
 type Action struct {
 	Name       string            `json:"name"`
 	Args       map[string]string `json:"args"`
 	UserArgMap []string          `json:"userArgMap"`
+
+	// Timeout limits each attempt, Retries is how many times a failed
+	// attempt is retried, waiting Backoff before the first retry and twice as
+	// long before each one after.
+	Timeout Duration `json:"timeout"`
+	Retries uint     `json:"retries"`
+	Backoff Duration `json:"backoff"`
+
+	// OnError is what happens when the action still fails, see the OnError
+	// constants. ErrorMessage is said in chat when it does.
+	OnError      string   `json:"onError"`
+	ErrorActions []Action `json:"errorActions"`
+	ErrorMessage string   `json:"errorMessage"`
 }
 
 type Command struct {
-	Name         string   `json:"name"`
-	Description  string   `json:"description"`
-	Enabled      bool     `json:"enabled"`
-	Offline      bool     `json:"offline"`
-	Points       uint64   `json:"points"`
-	Repeat       uint64   `json:"repeat"`
-	Actions      []Action `json:"actions"`
-	Restrictions []string `json:"restrictions"`
+	Name            string   `json:"name"`
+	Description     string   `json:"description"`
+	Enabled         bool     `json:"enabled"`
+	Offline         bool     `json:"offline"`
+	Points          uint64   `json:"points"`
+	Repeat          uint64   `json:"repeat"`
+	Actions         []Action `json:"actions"`
+	Restrictions    []string `json:"restrictions"`
+	GlobalCooldown  Duration `json:"globalCooldown"`
+	UserCooldown    Duration `json:"userCooldown"`
+	CooldownMessage string   `json:"cooldownMessage"`
+	Timeout         Duration `json:"timeout"`
+	Queue           string   `json:"queue"`
+
+	InsufficientPointsMessage string `json:"insufficientPointsMessage"`
 }
 
 func (c Command) UserPermitted(cmd Params) bool {
@@ -119,23 +101,28 @@ func (c Command) UserPermitted(cmd Params) bool {
 			}
 		case "follower":
 			// Get our own user id
-			if u, err := GetUser(cmd.UserID); err == nil {
-				fmt.Println("user:", u.DisplayName, u.IsFollower)
-				return u.IsFollower
+			u, err := GetUser(cmd.UserID)
+			if err != nil {
+				Log.WithError(err).WithFields(paramsFields(cmd)).Error("Failed to look up user")
+				break
 			}
+			return u.IsFollower
 		}
 	}
 	return false
 }
 
 type Params struct {
-	Channel     string
-	UserID      string
-	UserName    string
-	UserBadges  map[string]int
-	Command     string
-	CommandArgs []string
-	Payload     map[string]string
+	// Provider is the name of the chat provider the command came from, and
+	// the one replies go through.
+	Provider    string            `json:"provider"`
+	Channel     string            `json:"channel"`
+	UserID      string            `json:"userID"`
+	UserName    string            `json:"userName"`
+	UserBadges  map[string]int    `json:"userBadges"`
+	Command     string            `json:"command"`
+	CommandArgs []string          `json:"commandArgs"`
+	Payload     map[string]string `json:"payload"`
 }
 
 func (p Params) UserHasBadge(badge string) bool {
@@ -145,17 +132,52 @@ func (p Params) UserHasBadge(badge string) bool {
 	return false
 }
 
+// IsModerator returns true for moderators and the broadcaster.
+func (p Params) IsModerator() bool {
+	return p.UserHasBadge("moderator") || p.UserHasBadge("broadcaster")
+}
+
 type Module struct {
 	Name    string
 	Actions map[string]ActionFunc
 	Init    ModuleInitFunc
+
+	// Reconfigure is called with the module's new config when it changes on
+	// a config reload. Modules without it need a restart to pick up changes.
+	Reconfigure ModuleInitFunc
+
+	// ActionSpecs describe the arguments of each action, keyed like Actions.
+	ActionSpecs map[string]ActionSpec
+	// Triggers lists the full names of the triggers this module fires. A
+	// trailing * matches any suffix.
+	Triggers []string
+
+	// Health returns an error while the module isn't working, e.g. when it
+	// lost its connection. Reconnect is then called periodically until
+	// Health passes again.
+	Health    func() error
+	Reconnect func(ctx context.Context) error
+	// Shutdown is called when the bot stops, and should give up once ctx is
+	// done.
+	Shutdown func(ctx context.Context) error
 }
 
 type Trigger struct {
-	Actions []Action `json:"actions"`
+	Actions        []Action `json:"actions"`
+	GlobalCooldown Duration `json:"globalCooldown"`
+	UserCooldown   Duration `json:"userCooldown"`
+	Timeout        Duration `json:"timeout"`
+	Queue          string   `json:"queue"`
+	Cron           string   `json:"cron"`
+	Timezone       string   `json:"timezone"`
+
+	schedule cron.Schedule
 }
 
 func RegisterModule(m Module) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
 	if modules == nil {
 		modules = make([]Module, 0)
 	}
@@ -169,6 +191,36 @@ func RegisterModule(m Module) error {
 	return nil
 }
 
+func getModule(name string) *Module {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	for i := range modules {
+		if modules[i].Name == name {
+			m := modules[i]
+			return &m
+		}
+	}
+	return nil
+}
+
+// registeredModules returns every registered module, enabled or not.
+func registeredModules() []Module {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	return append([]Module{}, modules...)
+}
+
+func lookupAction(name string) (ActionFunc, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	f, ok := registeredActions[name]
+	return f, ok
+}
+
+// registerAction is called with registryLock held.
 func registerAction(module string, name string, f ActionFunc) error {
 	n := fmt.Sprintf("%s::%s", module, name)
 
@@ -185,122 +237,292 @@ func registerAction(module string, name string, f ActionFunc) error {
 	return nil
 }
 
-func ExecuteAction(module string, name string, args map[string]string, cmd Params) error {
-	action := fmt.Sprintf("%s::%s", module, name)
-	if f, ok := registeredActions[action]; ok {
-		return f(Action{Name: action, Args: args}, cmd)
+// callAction runs an action, recording how long it took and logging it if
+// it failed.
+func callAction(ctx context.Context, f ActionFunc, a Action, cmd Params) error {
+	start := time.Now()
+	err := f(ctx, a, cmd)
+	duration := time.Since(start)
+
+	actionDuration.WithLabelValues(a.Name, resultLabel(err)).Observe(duration.Seconds())
+
+	entry := Log.WithFields(paramsFields(cmd)).WithFields(logrus.Fields{
+		"action":   a.Name,
+		"duration": duration.String(),
+	})
+	if err != nil {
+		entry.WithError(err).Error("Action failed")
+	} else {
+		entry.Debug("Action finished")
+	}
+	return err
+}
+
+// ExecuteCommand runs a builtin, config or custom command and publishes a
+// CommandExecutedEvent with the outcome.
+func ExecuteCommand(ctx context.Context, cmd Params) error {
+	return recordCommand(cmd, func(points *uint64) error {
+		return executeCommand(ctx, cmd, points)
+	})
+}
+
+// recordCommand runs the command cmd with f, then logs and publishes a
+// CommandExecutedEvent with the outcome. f sets points to what the user was
+// charged.
+func recordCommand(cmd Params, f func(points *uint64) error) error {
+	start := time.Now()
+	var points uint64
+	err := f(&points)
+	duration := time.Since(start)
+
+	entry := Log.WithFields(paramsFields(cmd)).WithField("duration", duration.String())
+	if _, ok := err.(*CommandNotFoundError); ok {
+		// Anyone can type anything starting with !
+		entry.Debug("Command not found")
+	} else if err != nil {
+		entry.WithError(err).Error("Command failed")
+	} else {
+		entry.Info("Command executed")
 	}
-	return nil
+
+	Publish(CommandExecutedEvent{
+		Params:   cmd,
+		Points:   points,
+		Err:      err,
+		Duration: duration,
+	})
+	return err
 }
 
-func ExecuteCommand(cmd Params) error {
-	// This is a very special case command
+// executeCommand sets points to what the user was charged for the command,
+// if anything.
+func executeCommand(ctx context.Context, cmd Params, points *uint64) error {
+	// These are very special case commands
 	if strings.HasSuffix(cmd.Command, "++") {
 		counterName := strings.TrimRight(cmd.Command, "+")
 		current := IncrementCounter(counterName)
 
-		return TwitchSay(cmd, fmt.Sprintf("%s counter is now: %d", counterName, current))
+		return Reply(ctx, cmd, fmt.Sprintf("%s counter is now: %d", counterName, current))
+	}
+
+	if strings.HasSuffix(cmd.Command, "--") {
+		if !cmd.IsModerator() {
+			return nil
+		}
+
+		counterName := strings.TrimRight(cmd.Command, "-")
+		current := DecrementCounter(counterName)
+
+		return Reply(ctx, cmd, fmt.Sprintf("%s counter is now: %d", counterName, current))
 	}
 
 	// First look in builtin commands
 	if c, ok := builtinCommands[cmd.Command]; ok {
-		return c(cmd)
+		return c(ctx, cmd)
 	}
 
-	// Next check user created commands
-	if c, ok := config.Commands[cmd.Command]; ok && c.Enabled {
-		if !Status.Streaming && !c.Offline {
-			return nil
-		}
+	// Next check user created commands, from the config or added in chat
+	if c, ok := lookupCommand(currentConfig(), cmd.Command); ok && c.Enabled {
+		_, err := runCommand(ctx, c, cmd, points)
+		return err
+	}
 
-		if !c.UserPermitted(cmd) {
-			return nil
+	return &CommandNotFoundError{Command: cmd.Command}
+}
+
+// runCommand runs a user created command for cmd's user, if they're permitted
+// and it isn't on cooldown, charging them its points. points is set to what
+// they were charged. ran is false if the command was refused.
+func runCommand(ctx context.Context, c *Command, cmd Params, points *uint64) (ran bool, err error) {
+	if !Status.Streaming() && !c.Offline {
+		return false, nil
+	}
+
+	if !c.UserPermitted(cmd) {
+		return false, nil
+	}
+
+	cooldownKey := "command:" + c.Name
+	startedCooldown := false
+	if !cmd.CanBypassCooldown() {
+		remaining := cooldowns.acquire(cooldownKey, cmd.UserID, time.Duration(c.GlobalCooldown), time.Duration(c.UserCooldown))
+		if remaining > 0 {
+			if msg := cooldownMessage(c, cmd, remaining); msg != "" {
+				return false, Reply(ctx, cmd, msg)
+			}
+			return false, nil
 		}
+		startedCooldown = true
+	}
 
-		fmt.Println("Command executed", cmd.UserName, cmd.Command)
-		multiple := c.Repeat
-		if multiple == 0 {
-			multiple = 1
+	var u *User
+	if c.Points > 0 {
+		if u, err = GetUser(cmd.UserID); err != nil {
+			return false, err
 		}
 
-		var i uint64
-		for i = 0; i < multiple; i++ {
-			for _, a := range c.Actions {
-				if f, ok := registeredActions[a.Name]; ok {
-					for i, argName := range a.UserArgMap {
-						if len(cmd.CommandArgs) >= i+1 {
-							a.Args[argName] = cmd.CommandArgs[i]
-						}
-					}
-
-					if err := f(a, cmd); err != nil {
-						return err
-					}
+		if err := u.DebitPoints(c.Points); err != nil {
+			// They didn't get to run it, so don't hold it against them
+			if startedCooldown {
+				cooldowns.release(cooldownKey, cmd.UserID)
+			}
+
+			if e, ok := err.(*InsufficientPointsError); ok {
+				if msg := insufficientPointsMessage(c, cmd, e); msg != "" {
+					return false, Reply(ctx, cmd, msg)
 				}
+				return false, nil
 			}
+			return false, err
 		}
+		*points = c.Points
+	}
 
-		u, err := GetUser(cmd.UserID)
-		if err == nil && !u.New {
-			u.TakePoints(c.Points)
+	if c.Queue != "" {
+		err = runQueued(ctx, c.Queue, cmd, commandTimeout(c.Timeout), func(ctx context.Context) error {
+			return runCommandActions(ctx, c, cmd)
+		})
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout(c.Timeout))
+		err = runCommandActions(ctx, c, cmd)
+		cancel()
+	}
+	if err != nil && u != nil {
+		if refundErr := u.GivePoints(c.Points); refundErr != nil {
+			Log.WithError(refundErr).WithFields(paramsFields(cmd)).Error("Failed to refund points")
+		} else {
+			*points = 0
 		}
+	}
 
-		return nil
+	return true, err
+}
+
+// CommandNotFoundError is returned for commands that aren't builtin, in the
+// config or added in chat.
+type CommandNotFoundError struct {
+	Command string
+}
+
+func (e *CommandNotFoundError) Error() string {
+	return fmt.Sprintf("Command not found %s", e.Command)
+}
+
+func insufficientPointsMessage(c *Command, cmd Params, e *InsufficientPointsError) string {
+	msg := c.InsufficientPointsMessage
+	if msg == "" {
+		msg = currentConfig().InsufficientPointsMessage
 	}
 
-	return fmt.Errorf("Command not found %s", cmd.Command)
+	return RenderTemplate(msg, cmd, map[string]string{
+		"cost":    strconv.FormatUint(e.Cost, 10),
+		"balance": strconv.FormatUint(e.Balance, 10),
+		"missing": strconv.FormatUint(e.Missing(), 10),
+	})
 }
 
-func ExecuteTrigger(name string, cmd Params) error {
-	if t, ok := config.Triggers[name]; ok {
+func runCommandActions(ctx context.Context, c *Command, cmd Params) error {
+	multiple := c.Repeat
+	if multiple == 0 {
+		multiple = 1
+	}
 
-		for _, a := range t.Actions {
-			parts := strings.Split(a.Name, "::")
-			if len(parts) >= 2 {
-				ExecuteAction(parts[0], parts[1], a.Args, cmd)
-			}
+	var i uint64
+	for i = 0; i < multiple; i++ {
+		if err := runActions(ctx, c.Actions, cmd, OnErrorAbort); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func LoadConfig(r io.Reader) error {
-	dec := json.NewDecoder(r)
-	if err := dec.Decode(&config); err != nil {
+func ExecuteTrigger(ctx context.Context, name string, cmd Params) error {
+	if t, ok := currentConfig().Triggers[name]; ok {
+		if !cmd.CanBypassCooldown() {
+			remaining := cooldowns.acquire("trigger:"+name, cmd.UserID, time.Duration(t.GlobalCooldown), time.Duration(t.UserCooldown))
+			if remaining > 0 {
+				return nil
+			}
+		}
+
+		Log.WithFields(paramsFields(cmd)).WithField("trigger", name).Debug("Trigger fired")
+
+		start := time.Now()
+		err := executeTrigger(ctx, t, cmd)
+
+		Publish(TriggerExecutedEvent{
+			Name:     name,
+			Params:   cmd,
+			Err:      err,
+			Duration: time.Since(start),
+		})
 		return err
 	}
 
-	for key := range config.Commands {
-		cmd := config.Commands[key]
-		cmd.Name = key
+	return nil
+}
+
+func executeTrigger(ctx context.Context, t Trigger, cmd Params) error {
+	if t.Queue != "" {
+		return runQueued(ctx, t.Queue, cmd, commandTimeout(t.Timeout), func(ctx context.Context) error {
+			return runTriggerActions(ctx, t, cmd)
+		})
 	}
 
-	return nil
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout(t.Timeout))
+	defer cancel()
+	return runTriggerActions(ctx, t, cmd)
+}
+
+func runTriggerActions(ctx context.Context, t Trigger, cmd Params) error {
+	return runActions(ctx, t.Actions, cmd, OnErrorContinue)
 }
 
 func Init() error {
-	for _, m := range modules {
-		if IsModuleEnabled(m.Name) && m.Init != nil {
-			if err := m.Init(config.ModuleConfig[m.Name]); err != nil {
-				return err
-			}
+	InitModules()
+
+	// Bots only chatting elsewhere don't need Twitch credentials
+	if !IsModuleEnabled("twitch") {
+		return nil
+	}
+
+	// Like a module failing to initialize, WatchModules keeps trying
+	if err := InitTwitchAPI(); err != nil {
+		Log.WithError(err).Error("Failed to connect to the Twitch API, retrying in the background")
+	}
+	return nil
+}
+
+// InitTwitchAPI creates the Twitch API client used to look up users,
+// followers and stream info, with the twitch module's credentials.
+func InitTwitchAPI() error {
+	var tc struct {
+		ClientID     string `json:"clientID"`
+		ClientSecret string `json:"clientSecret"`
+	}
+	if c, ok := currentConfig().ModuleConfig["twitch"]; ok {
+		if err := json.Unmarshal(c, &tc); err != nil {
+			return err
 		}
 	}
-	var err error
-	helixClient, err = helix.NewClient(&helix.Options{
-		ClientID:     os.Getenv("TWITCH_CLIENT_ID"),
-		ClientSecret: os.Getenv("TWITCH_CLIENT_SECRET"),
+
+	client, err := helix.NewClient(&helix.Options{
+		ClientID:     tc.ClientID,
+		ClientSecret: tc.ClientSecret,
 	})
 	if err != nil {
 		return err
 	}
 
-	token, err := helixClient.GetAppAccessToken()
+	token, err := client.GetAppAccessToken()
 	if err != nil {
 		return err
 	}
+	client.SetUserAccessToken(token.Data.AccessToken)
 
-	helixClient.SetUserAccessToken(token.Data.AccessToken)
+	helixLock.Lock()
+	helixClient = client
+	helixLock.Unlock()
 	return nil
 }
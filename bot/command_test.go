@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"context"
+	"testing"
+)
+
+const pointsConfig = `{
+	"insufficientPointsMessage": "{{user}} needs {{missing}} more points",
+	"commands": {
+		"hydrate": {"enabled": true, "offline": true, "points": 100, "userCooldown": "1m", "actions": [{"name": "test::Record", "args": {"name": "hydrate"}}]},
+		"broken": {"enabled": true, "offline": true, "points": 100, "actions": [{"name": "test::Fail"}]}
+	}
+}`
+
+// setPoints gives user id a balance of points in the test database.
+func setPoints(t *testing.T, id string, points uint64) *User {
+	u, err := GetUser(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Points = points
+	if err := u.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestCommandPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		balance uint64
+		want    uint64
+		err     bool
+		ran     []string
+		replies []string
+	}{
+		{name: "affordable", command: "hydrate", balance: 150, want: 50, ran: []string{"hydrate"}},
+		{name: "exact", command: "hydrate", balance: 100, want: 0, ran: []string{"hydrate"}},
+		{name: "insufficient", command: "hydrate", balance: 40, want: 40, replies: []string{"erik needs 60 more points"}},
+		{name: "refunded on failure", command: "broken", balance: 150, want: 150, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, pointsConfig)
+			openTestDatabase(t)
+			u := setPoints(t, "1", tt.balance)
+
+			err := ExecuteCommand(context.Background(), Params{Provider: "test", UserID: "1", UserName: "erik", Command: tt.command})
+			if tt.err && err == nil {
+				t.Error("expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Error(err)
+			}
+
+			if u.Points != tt.want {
+				t.Errorf("balance %d, want %d", u.Points, tt.want)
+			}
+			users.Purge()
+			stored, err := GetUser("1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Points != tt.want {
+				t.Errorf("stored balance %d, want %d", stored.Points, tt.want)
+			}
+			if got := ranActions.take(); !sameItems(got, tt.ran) {
+				t.Errorf("ran %v, want %v", got, tt.ran)
+			}
+			if got := replies.take(); !sameItems(got, tt.replies) {
+				t.Errorf("replied %q, want %q", got, tt.replies)
+			}
+		})
+	}
+}
+
+// A user who couldn't afford a command can run it as soon as they can, the
+// cooldown only starts once they're charged.
+func TestInsufficientPointsReleasesCooldown(t *testing.T) {
+	useTestConfig(t, pointsConfig)
+	openTestDatabase(t)
+	u := setPoints(t, "1", 40)
+
+	cmd := Params{Provider: "test", UserID: "1", UserName: "erik", Command: "hydrate"}
+	if err := ExecuteCommand(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.GivePoints(60); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExecuteCommand(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"hydrate"}
+	if got := ranActions.take(); !sameItems(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,361 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type Config struct {
+	Commands       map[string]*Command        `json:"commands"`
+	Triggers       map[string]Trigger         `json:"triggers"`
+	ChatTriggers   []ChatTrigger              `json:"chatTriggers"`
+	Timers         map[string]*Timer          `json:"timers"`
+	Schedules      map[string]*Schedule       `json:"schedules"`
+	Timezone       string                     `json:"timezone"`
+	StreamCounters []string                   `json:"streamCounters"`
+	EnabledModules []string                   `json:"enabledModules"`
+	DatabasePath   string                     `json:"databasePath"`
+	WebPath        string                     `json:"webPath"`
+	MediaPath      string                     `json:"mediaPath"`
+	ModuleConfig   map[string]json.RawMessage `json:"moduleConfig"`
+	Plugins        map[string]PluginConfig    `json:"plugins"`
+	Log            LogConfig                  `json:"log"`
+	Audit          AuditConfig                `json:"audit"`
+
+	CooldownBypass  []string `json:"cooldownBypass"`
+	CooldownMessage string   `json:"cooldownMessage"`
+	CommandTimeout  Duration `json:"commandTimeout"`
+
+	InsufficientPointsMessage string `json:"insufficientPointsMessage"`
+
+	Queues map[string]QueueConfig `json:"queues"`
+
+	// Include lists files, globs or directories of config files to merge
+	// in, see loadIncludes
+	Include []string `json:"include,omitempty"`
+	// The included files and directories, to reload the config when they
+	// change
+	includedFiles []string
+	includedDirs  []string
+
+	// ModuleConfig with its secrets redacted, see resolveSecrets
+	redactedModuleConfig map[string]json.RawMessage
+}
+
+// The active config is never modified once loaded, reloading swaps in a new
+// one. Hold on to the result of currentConfig rather than calling it
+// repeatedly if you need a consistent view.
+var configLock sync.RWMutex
+var config = &Config{}
+var configPath string
+
+// Whether loading the config fails on a secret reference that can't be
+// resolved, see AllowUnresolvedSecrets.
+var requireSecrets = true
+
+// AllowUnresolvedSecrets keeps secret references that can't be resolved as
+// written when loading the config, rather than failing. For commands that
+// don't need the secrets, such as the one creating them.
+func AllowUnresolvedSecrets() {
+	requireSecrets = false
+}
+
+func currentConfig() *Config {
+	configLock.RLock()
+	defer configLock.RUnlock()
+
+	return config
+}
+
+func WebPath() string {
+	if p := currentConfig().WebPath; p != "" {
+		return p
+	}
+
+	path, _ := filepath.Abs(filepath.Dir(os.Args[0]))
+	return filepath.Join(path, "web")
+}
+
+func MediaPath() string {
+	if p := currentConfig().MediaPath; p != "" {
+		return p
+	}
+
+	path, _ := filepath.Abs(filepath.Dir(os.Args[0]))
+	return filepath.Join(path, "media")
+}
+
+func DatabasePath() string {
+	if p := currentConfig().DatabasePath; p != "" {
+		return p
+	}
+
+	path, _ := filepath.Abs(filepath.Dir(os.Args[0]))
+	return filepath.Join(path, "bot.db")
+}
+
+func IsModuleEnabled(m string) bool {
+	return currentConfig().moduleEnabled(m)
+}
+
+// Plugins are always enabled.
+func (c *Config) moduleEnabled(m string) bool {
+	if _, ok := c.Plugins[m]; ok {
+		return true
+	}
+
+	for _, mod := range c.EnabledModules {
+		if mod == m {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseConfig decodes and validates a JSON or YAML config without making it
+// active. Included files are relative to the working directory. Secret
+// references that can't be resolved are kept as written.
+func ParseConfig(r io.Reader) (*Config, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(b, "", false)
+}
+
+func validateConfig(c *Config) error {
+	if err := c.Log.validate(); err != nil {
+		return err
+	}
+
+	for _, m := range c.EnabledModules {
+		if getModule(m) == nil {
+			return fmt.Errorf("Enabled module '%s' does not exist", m)
+		}
+	}
+
+	for name, p := range c.Plugins {
+		if p.Command == "" {
+			return fmt.Errorf("Plugin '%s' needs a command", name)
+		}
+	}
+
+	for name, cmd := range c.Commands {
+		if cmd == nil {
+			return fmt.Errorf("Command '%s' is empty", name)
+		}
+		if err := validateActions(fmt.Sprintf("Command '%s'", name), cmd.Actions); err != nil {
+			return err
+		}
+	}
+
+	for name, t := range c.Triggers {
+		if err := validateActions(fmt.Sprintf("Trigger '%s'", name), t.Actions); err != nil {
+			return err
+		}
+	}
+
+	for i := range c.ChatTriggers {
+		if err := c.ChatTriggers[i].compile(); err != nil {
+			return err
+		}
+		if err := validateActions(fmt.Sprintf("Chat trigger '%s'", c.ChatTriggers[i].Name), c.ChatTriggers[i].Actions); err != nil {
+			return err
+		}
+	}
+
+	for name, t := range c.Timers {
+		if t == nil || t.Interval <= 0 {
+			return fmt.Errorf("Timer '%s' needs an interval", name)
+		}
+		if err := validateActions(fmt.Sprintf("Timer '%s'", name), t.Actions); err != nil {
+			return err
+		}
+	}
+
+	if err := c.compileSchedules(); err != nil {
+		return err
+	}
+	for name, s := range c.Schedules {
+		if err := validateActions(fmt.Sprintf("Schedule '%s'", name), s.Actions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func LoadConfig(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	c, err := parseConfig(b, "", requireSecrets)
+	if err != nil {
+		return err
+	}
+
+	useConfig(c)
+	return nil
+}
+
+func useConfig(c *Config) {
+	configureLogging(c.Log)
+	warnConfig(c)
+
+	configLock.Lock()
+	config = c
+	configLock.Unlock()
+}
+
+// warnConfig logs problems that don't stop a config from loading, such as
+// unknown actions, which are skipped when a command runs.
+func warnConfig(c *Config) {
+	for _, err := range ValidateConfig(c) {
+		Log.Warnf("Config warning: %s", err)
+	}
+}
+
+// LoadConfigFile loads the config at path, and the .env file next to it, and
+// remembers it for ReloadConfig.
+func LoadConfigFile(path string) error {
+	c, err := parseConfigFile(path, requireSecrets)
+	if err != nil {
+		return err
+	}
+
+	useConfig(c)
+	configPath = path
+	Log.WithField("path", path).Info("Using config")
+	return nil
+}
+
+// ReloadConfig re-reads the config file and its .env file. The new config is only swapped in if
+// it is valid. Enabled modules whose moduleConfig changed are passed the new
+// config through their Reconfigure hook, modules without one keep running
+// with their old config until the bot is restarted.
+func ReloadConfig() error {
+	if configPath == "" {
+		return fmt.Errorf("No config file loaded")
+	}
+
+	c, err := parseConfigFile(configPath, requireSecrets)
+	if err != nil {
+		return err
+	}
+	configureLogging(c.Log)
+	warnConfig(c)
+
+	configLock.Lock()
+	old := config
+	config = c
+	configLock.Unlock()
+
+	if !bytes.Equal(old.ModuleConfig["twitch"], c.ModuleConfig["twitch"]) {
+		resetChannelCache()
+	}
+
+	if !reflect.DeepEqual(old.Plugins, c.Plugins) {
+		Log.Warn("Plugins changed, restart the bot to apply them")
+	}
+
+	for _, m := range registeredModules() {
+		if !c.moduleEnabled(m.Name) {
+			continue
+		}
+
+		if !old.moduleEnabled(m.Name) {
+			Log.WithField("module", m.Name).Warn("Module was enabled, restart the bot to start it")
+			continue
+		}
+
+		if bytes.Equal(old.ModuleConfig[m.Name], c.ModuleConfig[m.Name]) {
+			continue
+		}
+
+		if m.Reconfigure == nil {
+			Log.WithField("module", m.Name).Warn("Module does not support reconfiguring, restart the bot to apply its new config")
+			continue
+		}
+
+		if err := m.Reconfigure(c.ModuleConfig[m.Name]); err != nil {
+			Log.WithError(err).WithField("module", m.Name).Error("Failed to reconfigure module")
+		}
+	}
+
+	Log.WithField("path", configPath).Info("Reloaded config")
+	return nil
+}
+
+// WatchConfig reloads the config whenever the file changes. It blocks, so run
+// it on its own goroutine.
+func WatchConfig() error {
+	if configPath == "" {
+		return fmt.Errorf("No config file loaded")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// Watch the directories rather than the files, editors often replace the
+	// file on save which would drop a watch on the file itself.
+	path, err := filepath.Abs(configPath)
+	if err != nil {
+		return err
+	}
+	files, dirs := currentConfig().watchedPaths(path)
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	// Saves tend to arrive as several events, wait for them to settle.
+	var reload <-chan time.Time
+	for {
+		select {
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			name := filepath.Clean(e.Name)
+			changed := files[name] || (dirs[filepath.Dir(name)] == includedDir && isConfigFile(name))
+			if changed && e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+				reload = time.After(500 * time.Millisecond)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			Log.WithError(err).Error("Error watching config")
+		case <-reload:
+			reload = nil
+			if err := ReloadConfig(); err != nil {
+				Log.WithError(err).Error("Failed to reload config")
+				continue
+			}
+
+			// Includes may have changed
+			files, dirs = currentConfig().watchedPaths(path)
+			for dir := range dirs {
+				if err := watcher.Add(dir); err != nil {
+					Log.WithError(err).WithField("path", dir).Error("Failed to watch config directory")
+				}
+			}
+		}
+	}
+}
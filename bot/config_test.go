@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadConfig(t *testing.T) {
+	var reconfigured recorder
+	RegisterModule(Module{
+		Name: "reloadable",
+		Reconfigure: func(c json.RawMessage) error {
+			reconfigured.add(string(c))
+			return nil
+		},
+	})
+	forgetModule(t, "reloadable")
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	write := func(s string) {
+		if err := ioutil.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	useTestConfig(t, `{}`)
+	oldPath := configPath
+	t.Cleanup(func() {
+		configPath = oldPath
+	})
+
+	write(`{"enabledModules": ["reloadable"], "moduleConfig": {"reloadable": {"n": 1}}, "cooldownMessage": "first"}`)
+	if err := LoadConfigFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		config       string
+		err          bool
+		message      string
+		reconfigured []string
+	}{
+		{
+			name:    "unchanged module config",
+			config:  `{"enabledModules": ["reloadable"], "moduleConfig": {"reloadable": {"n": 1}}, "cooldownMessage": "second"}`,
+			message: "second",
+		},
+		{
+			name:         "changed module config",
+			config:       `{"enabledModules": ["reloadable"], "moduleConfig": {"reloadable": {"n": 2}}, "cooldownMessage": "second"}`,
+			message:      "second",
+			reconfigured: []string{`{"n":2}`},
+		},
+		{
+			name:    "invalid",
+			config:  `{"enabledModules": ["reloadable"], "log": {"level": "loud"}}`,
+			err:     true,
+			message: "second",
+		},
+		{
+			name:    "module disabled",
+			config:  `{"moduleConfig": {"reloadable": {"n": 3}}, "cooldownMessage": "third"}`,
+			message: "third",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write(tt.config)
+			err := ReloadConfig()
+			if tt.err && err == nil {
+				t.Error("expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Error(err)
+			}
+
+			if got := currentConfig().CooldownMessage; got != tt.message {
+				t.Errorf("cooldown message %q, want %q", got, tt.message)
+			}
+			if got := reconfigured.take(); !sameItems(got, tt.reconfigured) {
+				t.Errorf("reconfigured with %q, want %q", got, tt.reconfigured)
+			}
+		})
+	}
+}
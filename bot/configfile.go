@@ -0,0 +1,320 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// The parts of a config an included file may set. Their entries are merged
+// into the main config, an entry defined twice is an error.
+var includableKeys = []string{"commands", "triggers", "chatTriggers", "timers", "schedules", "queues", "plugins", "moduleConfig"}
+
+// isConfigFile reports whether path has an extension the bot can read.
+func isConfigFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// configJSON converts the config read from path to JSON, parsing it as JSON
+// or YAML by its extension. A config without either extension, such as one
+// read from stdin, is JSON if it's valid JSON and YAML otherwise.
+func configJSON(b []byte, path string) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return b, nil
+	case ".yaml", ".yml":
+		return yaml.YAMLToJSON(b)
+	}
+
+	if json.Valid(b) {
+		return b, nil
+	}
+	return yaml.YAMLToJSON(b)
+}
+
+// ParseConfigFile loads the .env file next to the config at path, if there
+// is one, then parses the config and the files it includes. Secret
+// references that can't be resolved are kept as written, so a config can be
+// checked without its secrets.
+func ParseConfigFile(path string) (*Config, error) {
+	return parseConfigFile(path, false)
+}
+
+// parseConfigFile is ParseConfigFile, failing on a secret reference that
+// can't be resolved if strict is set.
+func parseConfigFile(path string, strict bool) (*Config, error) {
+	if err := LoadDotEnv(filepath.Join(filepath.Dir(path), ".env")); err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(b, path, strict)
+}
+
+func parseConfig(b []byte, path string, strict bool) (*Config, error) {
+	b, err := configJSON(b, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&c); err != nil {
+		return nil, err
+	}
+
+	if len(c.Include) > 0 {
+		if err := c.loadIncludes(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for key := range c.Commands {
+		cmd := c.Commands[key]
+		cmd.Name = key
+	}
+
+	if err := validateConfig(&c); err != nil {
+		return nil, err
+	}
+	if err := c.resolveSecrets(strict); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// loadIncludes merges the files listed in include into c. Entries are paths
+// or globs relative to the main config file, a directory includes every
+// config file in it. Files are merged in the order listed, a directory's in
+// name order.
+func (c *Config) loadIncludes(path string) error {
+	dir, name := filepath.Dir(path), path
+	if path == "" {
+		name = "the config"
+	}
+
+	// Where each entry came from, to say where a duplicate was first defined
+	defined := make(map[string]string)
+	for _, key := range includableKeys {
+		for _, entry := range configEntries(c, key) {
+			defined[key+"."+entry] = name
+		}
+	}
+
+	for _, inc := range c.Include {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(dir, inc)
+		}
+
+		files, dirs, err := includedFiles(inc)
+		if err != nil {
+			return err
+		}
+		c.includedDirs = append(c.includedDirs, dirs...)
+		if len(files) == 0 {
+			return fmt.Errorf("Include '%s' matches no config files", inc)
+		}
+
+		for _, f := range files {
+			if err := c.include(f, defined); err != nil {
+				return err
+			}
+			c.includedFiles = append(c.includedFiles, f)
+		}
+	}
+	return nil
+}
+
+// includedFiles expands an include entry to the config files it names, and
+// the directories it includes.
+func includedFiles(pattern string) ([]string, []string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid include '%s': %s", pattern, err)
+	}
+
+	var files, dirs []string
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, m)
+			continue
+		}
+
+		dirs = append(dirs, m)
+		entries, err := ioutil.ReadDir(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && isConfigFile(e.Name()) {
+				files = append(files, filepath.Join(m, e.Name()))
+			}
+		}
+	}
+	return files, dirs, nil
+}
+
+// include merges the included file at path into c.
+func (c *Config) include(path string, defined map[string]string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if b, err = configJSON(b, path); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	for key := range keys {
+		if !includable(key) {
+			return fmt.Errorf("%s: '%s' can't be set in an included file, only %s", path, key, strings.Join(includableKeys, ", "))
+		}
+	}
+
+	var inc Config
+	if err := json.Unmarshal(b, &inc); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	for _, key := range includableKeys {
+		for _, entry := range configEntries(&inc, key) {
+			if first, ok := defined[key+"."+entry]; ok {
+				return fmt.Errorf("%s: %s '%s' is already defined in %s", path, strings.TrimSuffix(key, "s"), entry, first)
+			}
+			defined[key+"."+entry] = path
+		}
+	}
+
+	mergeMap(&c.Commands, inc.Commands)
+	mergeMap(&c.Triggers, inc.Triggers)
+	mergeMap(&c.Timers, inc.Timers)
+	mergeMap(&c.Schedules, inc.Schedules)
+	mergeMap(&c.Queues, inc.Queues)
+	mergeMap(&c.Plugins, inc.Plugins)
+	mergeMap(&c.ModuleConfig, inc.ModuleConfig)
+	c.ChatTriggers = append(c.ChatTriggers, inc.ChatTriggers...)
+	return nil
+}
+
+// mergeMap adds the entries of the map src to the map dst points to.
+func mergeMap(dst interface{}, src interface{}) {
+	d := reflect.ValueOf(dst).Elem()
+	s := reflect.ValueOf(src)
+	if s.Len() == 0 {
+		return
+	}
+
+	if d.IsNil() {
+		d.Set(reflect.MakeMap(d.Type()))
+	}
+	for _, k := range s.MapKeys() {
+		d.SetMapIndex(k, s.MapIndex(k))
+	}
+}
+
+// mapKeys returns the keys of a map with string keys.
+func mapKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	return keys
+}
+
+// How a directory is watched for config changes.
+type watchedDir int
+
+const (
+	// The directory holds config files, only they are watched
+	fileDir watchedDir = iota + 1
+	// The directory is included, any config file added to it is watched
+	includedDir
+)
+
+// watchedPaths returns the files whose changes reload the config at path,
+// and the directories to watch for them. Paths are absolute.
+func (c *Config) watchedPaths(path string) (map[string]bool, map[string]watchedDir) {
+	files := make(map[string]bool)
+	dirs := make(map[string]watchedDir)
+
+	add := func(f string) {
+		if abs, err := filepath.Abs(f); err == nil {
+			files[abs] = true
+			if dirs[filepath.Dir(abs)] == 0 {
+				dirs[filepath.Dir(abs)] = fileDir
+			}
+		}
+	}
+
+	add(path)
+	add(filepath.Join(filepath.Dir(path), ".env"))
+	for _, f := range c.includedFiles {
+		add(f)
+	}
+	for _, d := range c.includedDirs {
+		if abs, err := filepath.Abs(d); err == nil {
+			dirs[abs] = includedDir
+		}
+	}
+	return files, dirs
+}
+
+func includable(key string) bool {
+	for _, k := range includableKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// configEntries returns the names of the entries c defines under key. Chat
+// triggers without a name can't clash.
+func configEntries(c *Config, key string) []string {
+	var names []string
+	switch key {
+	case "commands":
+		names = mapKeys(c.Commands)
+	case "triggers":
+		names = mapKeys(c.Triggers)
+	case "timers":
+		names = mapKeys(c.Timers)
+	case "schedules":
+		names = mapKeys(c.Schedules)
+	case "queues":
+		names = mapKeys(c.Queues)
+	case "plugins":
+		names = mapKeys(c.Plugins)
+	case "moduleConfig":
+		names = mapKeys(c.ModuleConfig)
+	case "chatTriggers":
+		for _, t := range c.ChatTriggers {
+			if t.Name != "" {
+				names = append(names, t.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
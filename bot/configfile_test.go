@@ -0,0 +1,250 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFiles writes files, keyed by their path relative to a new
+// temporary directory, and returns the directory.
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const sayHi = `{"enabled": true, "actions": [{"name": "bot::Say", "args": {"message": "hi"}}]}`
+
+func TestParseConfigFileIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `# comments are fine in YAML
+include:
+  - commands.d
+  - extra.json
+commands:
+  hi:
+    enabled: true
+    actions:
+      - name: bot::Say
+        args: {message: hi}
+`,
+		"commands.d/b.yaml":    "commands:\n  lurk: " + sayHi + "\n",
+		"commands.d/a.yml":     "triggers:\n  bot::Startup:\n    actions: [{name: bot::Say, args: {message: up}}]\n",
+		"commands.d/notes.txt": "not a config file",
+		"extra.json":           `{"timers": {"reminder": {"interval": "5m", "actions": []}}, "moduleConfig": {"obs": {"port": "4444"}}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	c, err := ParseConfigFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := mapKeys(c.Commands), []string{"hi", "lurk"}; !sameKeys(got, want) {
+		t.Errorf("commands %v, want %v", got, want)
+	}
+	if _, ok := c.Triggers["bot::Startup"]; !ok {
+		t.Errorf("trigger from commands.d/a.yml wasn't merged")
+	}
+	if _, ok := c.Timers["reminder"]; !ok {
+		t.Errorf("timer from extra.json wasn't merged")
+	}
+	if _, ok := c.ModuleConfig["obs"]; !ok {
+		t.Errorf("module config from extra.json wasn't merged")
+	}
+
+	wantFiles := []string{
+		filepath.Join(dir, "commands.d", "a.yml"),
+		filepath.Join(dir, "commands.d", "b.yaml"),
+		filepath.Join(dir, "extra.json"),
+	}
+	if !reflect.DeepEqual(c.includedFiles, wantFiles) {
+		t.Errorf("included files %v, want %v", c.includedFiles, wantFiles)
+	}
+}
+
+func TestParseConfigFileIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// Every one of these must be in the error
+		want []string
+	}{
+		{
+			name: "command defined in main and included file",
+			files: map[string]string{
+				"config.json": `{"include": ["more.yaml"], "commands": {"hi": ` + sayHi + `}}`,
+				"more.yaml":   "commands:\n  hi: " + sayHi + "\n",
+			},
+			want: []string{"more.yaml: command 'hi' is already defined in ", "config.json"},
+		},
+		{
+			name: "command defined in two included files",
+			files: map[string]string{
+				"config.json":       `{"include": ["commands.d"]}`,
+				"commands.d/a.json": `{"commands": {"hi": ` + sayHi + `}}`,
+				"commands.d/b.json": `{"commands": {"hi": ` + sayHi + `}}`,
+			},
+			want: []string{"b.json: command 'hi' is already defined in ", "a.json"},
+		},
+		{
+			name: "module config defined twice",
+			files: map[string]string{
+				"config.json": `{"include": ["obs.json"], "moduleConfig": {"obs": {}}}`,
+				"obs.json":    `{"moduleConfig": {"obs": {}}}`,
+			},
+			want: []string{"obs.json: moduleConfig 'obs' is already defined in "},
+		},
+		{
+			name: "chat trigger defined twice",
+			files: map[string]string{
+				"config.json": `{"include": ["chat.json"], "chatTriggers": [{"name": "kb", "keywords": ["keyboard"], "actions": [{"name": "bot::Say"}]}]}`,
+				"chat.json":   `{"chatTriggers": [{"name": "kb", "keywords": ["keys"], "actions": [{"name": "bot::Say"}]}]}`,
+			},
+			want: []string{"chat.json: chatTrigger 'kb' is already defined in "},
+		},
+		{
+			name: "nested include",
+			files: map[string]string{
+				"config.json": `{"include": ["more.json"]}`,
+				"more.json":   `{"include": ["even-more.json"]}`,
+			},
+			want: []string{"more.json: 'include' can't be set in an included file"},
+		},
+		{
+			name: "setting that can't be included",
+			files: map[string]string{
+				"config.json": `{"include": ["more.yaml"]}`,
+				"more.yaml":   "timezone: UTC\n",
+			},
+			want: []string{"more.yaml: 'timezone' can't be set in an included file"},
+		},
+		{
+			name: "invalid YAML",
+			files: map[string]string{
+				"config.json": `{"include": ["more.yaml"]}`,
+				"more.yaml":   "commands: [\n",
+			},
+			want: []string{"more.yaml: "},
+		},
+		{
+			name: "include matching nothing",
+			files: map[string]string{
+				"config.json": `{"include": ["missing/*.yaml"]}`,
+			},
+			want: []string{"matches no config files"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, tt.files)
+			defer os.RemoveAll(dir)
+
+			_, err := ParseConfigFile(filepath.Join(dir, "config.json"))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConfigYAML(t *testing.T) {
+	c, err := ParseConfig(strings.NewReader("commandTimeout: 30s\ncommands:\n  hi: " + sayHi + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CommandTimeout != Duration(30*time.Second) {
+		t.Errorf("commandTimeout %s, want 30s", c.CommandTimeout)
+	}
+	if cmd, ok := c.Commands["hi"]; !ok || cmd.Name != "hi" {
+		t.Errorf("command hi wasn't parsed: %v", c.Commands)
+	}
+}
+
+func TestConfigJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		want    string
+		err     bool
+	}{
+		{name: "JSON", path: "config.json", content: `{"timezone": "UTC"}`, want: `{"timezone": "UTC"}`},
+		{name: "YAML", path: "config.yaml", content: "timezone: UTC\n", want: `{"timezone":"UTC"}`},
+		{name: "YAML flow mapping", path: "config.yml", content: "{timezone: UTC, enabledModules: [bot]}\n", want: `{"enabledModules":["bot"],"timezone":"UTC"}`},
+		{name: "extension ignores case", path: "CONFIG.YAML", content: "{timezone: UTC}", want: `{"timezone":"UTC"}`},
+		{name: "invalid JSON stays JSON", path: "config.json", content: `{timezone: UTC}`, want: `{timezone: UTC}`},
+		{name: "invalid YAML", path: "config.yaml", content: "timezone: [\n", err: true},
+		{name: "no extension, JSON", content: `{"timezone": "UTC"}`, want: `{"timezone": "UTC"}`},
+		{name: "no extension, YAML flow mapping", content: "{timezone: UTC}", want: `{"timezone":"UTC"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := configJSON([]byte(tt.content), tt.path)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigFileYAMLFlowMapping(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml":   "{include: [commands.yaml], timezone: UTC}\n",
+		"commands.yaml": "{commands: {hi: {enabled: true, actions: [{name: bot::Say, args: {message: hi}}]}}}\n",
+	})
+	defer os.RemoveAll(dir)
+
+	c, err := ParseConfigFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Timezone != "UTC" {
+		t.Errorf("timezone %q, want UTC", c.Timezone)
+	}
+	if _, ok := c.Commands["hi"]; !ok {
+		t.Errorf("command hi wasn't merged: %v", c.Commands)
+	}
+}
+
+func sameKeys(a, b []string) bool {
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return reflect.DeepEqual(a, b)
+}
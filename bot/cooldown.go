@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+var defaultCooldownBypass = []string{"broadcaster", "moderator"}
+
+// Expired cooldowns are swept out this often, so users who chatted once don't
+// stay in the tracker forever.
+const cooldownSweepInterval = time.Minute
+
+type cooldownTracker struct {
+	lock sync.Mutex
+	// When each active cooldown ends
+	ends      map[string]time.Time
+	lastSweep time.Time
+}
+
+var cooldowns = newCooldownTracker()
+
+func newCooldownTracker() *cooldownTracker {
+	return &cooldownTracker{
+		ends:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// acquire checks the global and per-user cooldowns for key. If neither is
+// active both are started and zero is returned, otherwise nothing changes and
+// the time left on the longest active cooldown is returned.
+func (c *cooldownTracker) acquire(key string, userID string, global time.Duration, user time.Duration) time.Duration {
+	return c.acquireAt(time.Now(), key, userID, global, user)
+}
+
+func (c *cooldownTracker) acquireAt(now time.Time, key string, userID string, global time.Duration, user time.Duration) time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sweep(now)
+
+	userKey := key + ":" + userID
+	trackUser := user > 0 && userID != ""
+
+	var remaining time.Duration
+	if end, ok := c.ends[key]; ok && global > 0 {
+		if r := end.Sub(now); r > remaining {
+			remaining = r
+		}
+	}
+	if end, ok := c.ends[userKey]; ok && trackUser {
+		if r := end.Sub(now); r > remaining {
+			remaining = r
+		}
+	}
+
+	if remaining > 0 {
+		return remaining
+	}
+
+	if global > 0 {
+		c.ends[key] = now.Add(global)
+	}
+	if trackUser {
+		c.ends[userKey] = now.Add(user)
+	}
+	return 0
+}
+
+// sweep forgets the cooldowns that have ended, at most once per
+// cooldownSweepInterval. Called with the lock held.
+func (c *cooldownTracker) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < cooldownSweepInterval {
+		return
+	}
+	c.lastSweep = now
+
+	for key, end := range c.ends {
+		if !end.After(now) {
+			delete(c.ends, key)
+		}
+	}
+}
+
+// release clears the cooldowns started by acquire, for when the command never
+// ran after all.
+func (c *cooldownTracker) release(key string, userID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.ends, key)
+	delete(c.ends, key+":"+userID)
+}
+
+// CanBypassCooldown returns true if the user has one of the badges listed in
+// the "cooldownBypass" config (broadcaster and moderator by default).
+func (p Params) CanBypassCooldown() bool {
+	bypass := currentConfig().CooldownBypass
+	if bypass == nil {
+		bypass = defaultCooldownBypass
+	}
+
+	for _, badge := range bypass {
+		if p.UserHasBadge(badge) {
+			return true
+		}
+	}
+	return false
+}
+
+func cooldownMessage(c *Command, cmd Params, remaining time.Duration) string {
+	msg := c.CooldownMessage
+	if msg == "" {
+		msg = currentConfig().CooldownMessage
+	}
+
+	return RenderTemplate(msg, cmd, map[string]string{
+		"remaining": formatRemaining(remaining),
+	})
+}
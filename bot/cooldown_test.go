@@ -0,0 +1,117 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCooldownAcquire(t *testing.T) {
+	type attempt struct {
+		at     time.Duration
+		user   string
+		global time.Duration
+		per    time.Duration
+		want   time.Duration
+	}
+
+	tests := []struct {
+		name     string
+		attempts []attempt
+	}{
+		{
+			name: "no cooldowns",
+			attempts: []attempt{
+				{at: 0, user: "a"},
+				{at: 0, user: "a"},
+			},
+		},
+		{
+			name: "global",
+			attempts: []attempt{
+				{at: 0, user: "a", global: time.Minute},
+				{at: 20 * time.Second, user: "b", global: time.Minute, want: 40 * time.Second},
+				{at: time.Minute, user: "b", global: time.Minute},
+			},
+		},
+		{
+			name: "per user",
+			attempts: []attempt{
+				{at: 0, user: "a", per: time.Minute},
+				{at: 0, user: "b", per: time.Minute},
+				{at: 30 * time.Second, user: "a", per: time.Minute, want: 30 * time.Second},
+				{at: time.Minute, user: "a", per: time.Minute},
+			},
+		},
+		{
+			name: "longest active cooldown",
+			attempts: []attempt{
+				{at: 0, user: "a", global: 10 * time.Second, per: time.Minute},
+				{at: 5 * time.Second, user: "a", global: 10 * time.Second, per: time.Minute, want: 55 * time.Second},
+				{at: 5 * time.Second, user: "b", global: 10 * time.Second, per: time.Minute, want: 5 * time.Second},
+				{at: 10 * time.Second, user: "b", global: 10 * time.Second, per: time.Minute},
+			},
+		},
+		{
+			name: "refused attempt doesn't restart it",
+			attempts: []attempt{
+				{at: 0, user: "a", global: time.Minute},
+				{at: 50 * time.Second, user: "a", global: time.Minute, want: 10 * time.Second},
+				{at: time.Minute, user: "a", global: time.Minute},
+			},
+		},
+		{
+			name: "no per user cooldown without a user",
+			attempts: []attempt{
+				{at: 0, per: time.Minute},
+				{at: 0, per: time.Minute},
+			},
+		},
+	}
+
+	start := time.Now()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCooldownTracker()
+			for i, a := range tt.attempts {
+				if got := c.acquireAt(start.Add(a.at), "command:hi", a.user, a.global, a.per); got != a.want {
+					t.Errorf("attempt %d: remaining %s, want %s", i+1, got, a.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCooldownRelease(t *testing.T) {
+	c := newCooldownTracker()
+	now := time.Now()
+
+	c.acquireAt(now, "command:hi", "a", time.Minute, time.Minute)
+	c.release("command:hi", "a")
+	if got := c.acquireAt(now, "command:hi", "a", time.Minute, time.Minute); got != 0 {
+		t.Errorf("remaining %s after release, want 0", got)
+	}
+}
+
+func TestCooldownSweep(t *testing.T) {
+	c := newCooldownTracker()
+	now := c.lastSweep
+
+	for _, user := range []string{"a", "b", "c"} {
+		c.acquireAt(now, "command:hi", user, 0, time.Second)
+	}
+	c.acquireAt(now, "command:long", "a", 0, time.Hour)
+	if len(c.ends) != 4 {
+		t.Fatalf("tracking %d cooldowns, want 4", len(c.ends))
+	}
+
+	// Not swept before the interval
+	c.acquireAt(now.Add(cooldownSweepInterval/2), "command:other", "", 0, 0)
+	if len(c.ends) != 4 {
+		t.Errorf("tracking %d cooldowns before the sweep, want 4", len(c.ends))
+	}
+
+	c.acquireAt(now.Add(cooldownSweepInterval), "command:other", "", 0, 0)
+	if _, ok := c.ends["command:long:a"]; len(c.ends) != 1 || !ok {
+		t.Errorf("tracking %v after the sweep, want only command:long:a", c.ends)
+	}
+}
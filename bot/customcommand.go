@@ -0,0 +1,272 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// These check builtinCommands themselves, so can't be part of its initializer
+func init() {
+	builtinCommands["addcom"] = addCommandCmd
+	builtinCommands["editcom"] = editCommandCmd
+	builtinCommands["delcom"] = deleteCommandCmd
+	builtinCommands["aliascom"] = aliasCommandCmd
+}
+
+// CustomCommand is a command added from chat with !addcom or !aliascom. It
+// either says Response, rendered as a template, or runs the command named by
+// Alias.
+type CustomCommand struct {
+	Name      string    `json:"name"`
+	Response  string    `json:"response"`
+	Alias     string    `json:"alias"`
+	CreatedBy string    `json:"createdBy"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (cc CustomCommand) command() *Command {
+	return &Command{
+		Name:    cc.Name,
+		Enabled: true,
+		Offline: true,
+		Actions: []Action{
+			{Name: "bot::Say", Args: map[string]string{"message": cc.Response}},
+		},
+	}
+}
+
+func GetCustomCommand(name string) (*CustomCommand, error) {
+	var cc *CustomCommand
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(CUSTOM_COMMAND_BUCKET).Get([]byte(name))
+		if v == nil {
+			return nil
+		}
+
+		cc = &CustomCommand{}
+		return json.Unmarshal(v, cc)
+	})
+
+	return cc, err
+}
+
+func SaveCustomCommand(cc CustomCommand) error {
+	cc.UpdatedAt = time.Now()
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		j, err := json.Marshal(cc)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(CUSTOM_COMMAND_BUCKET).Put([]byte(cc.Name), j)
+	})
+}
+
+func DeleteCustomCommand(name string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(CUSTOM_COMMAND_BUCKET).Delete([]byte(name))
+	})
+}
+
+func ListCustomCommands() []CustomCommand {
+	cmds := make([]CustomCommand, 0)
+
+	db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(CUSTOM_COMMAND_BUCKET).ForEach(func(k, v []byte) error {
+			var cc CustomCommand
+			if err := json.Unmarshal(v, &cc); err == nil {
+				cmds = append(cmds, cc)
+			}
+			return nil
+		})
+	})
+
+	return cmds
+}
+
+// lookupCommand finds a command in the config, falling back to custom
+// commands. The config wins when both define the same name. Aliases resolve
+// to the command they point at, so they share its cooldowns.
+func lookupCommand(c *Config, name string) (*Command, bool) {
+	// Aliases may point at other aliases, but not forever
+	for i := 0; i < 5; i++ {
+		if cmd, ok := c.Commands[name]; ok {
+			return cmd, true
+		}
+
+		if db == nil {
+			return nil, false
+		}
+
+		cc, err := GetCustomCommand(name)
+		if err != nil || cc == nil {
+			return nil, false
+		}
+		if cc.Alias == "" {
+			return cc.command(), true
+		}
+		name = cc.Alias
+	}
+
+	return nil, false
+}
+
+// commandExists reports whether name is taken by a builtin, config or custom
+// command.
+func commandExists(name string) bool {
+	if _, ok := builtinCommands[name]; ok {
+		return true
+	}
+	if _, ok := currentConfig().Commands[name]; ok {
+		return true
+	}
+	cc, _ := GetCustomCommand(name)
+	return cc != nil
+}
+
+// commandNames returns the names of every enabled config command and custom
+// command, sorted.
+func commandNames() []string {
+	c := currentConfig()
+
+	names := make([]string, 0)
+	for _, cmd := range c.Commands {
+		if cmd.Enabled {
+			names = append(names, cmd.Name)
+		}
+	}
+
+	if db != nil {
+		for _, cc := range ListCustomCommands() {
+			if _, ok := c.Commands[cc.Name]; !ok {
+				names = append(names, cc.Name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func customCommandName(name string) string {
+	return strings.ToLower(strings.TrimPrefix(name, "!"))
+}
+
+// addCommandCmd adds a custom command: !addcom discord Join us at {{args.1}}
+func addCommandCmd(ctx context.Context, cmd Params) error {
+	if !cmd.IsModerator() {
+		return nil
+	}
+	if len(cmd.CommandArgs) < 2 {
+		return Reply(ctx, cmd, "usage: !addcom <name> <response>")
+	}
+
+	name := customCommandName(cmd.CommandArgs[0])
+	if commandExists(name) {
+		return Reply(ctx, cmd, fmt.Sprintf("!%s already exists", name))
+	}
+
+	err := SaveCustomCommand(CustomCommand{
+		Name:      name,
+		Response:  strings.Join(cmd.CommandArgs[1:], " "),
+		CreatedBy: cmd.UserName,
+	})
+	if err != nil {
+		return err
+	}
+
+	return Reply(ctx, cmd, fmt.Sprintf("Added !%s", name))
+}
+
+// editCommandCmd replaces the response of a custom command, turning an alias
+// into a regular command.
+func editCommandCmd(ctx context.Context, cmd Params) error {
+	if !cmd.IsModerator() {
+		return nil
+	}
+	if len(cmd.CommandArgs) < 2 {
+		return Reply(ctx, cmd, "usage: !editcom <name> <response>")
+	}
+
+	name := customCommandName(cmd.CommandArgs[0])
+	cc, err := GetCustomCommand(name)
+	if err != nil {
+		return err
+	}
+	if cc == nil {
+		return Reply(ctx, cmd, fmt.Sprintf("!%s is not a custom command", name))
+	}
+
+	cc.Response = strings.Join(cmd.CommandArgs[1:], " ")
+	cc.Alias = ""
+	if err := SaveCustomCommand(*cc); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("Updated !%s", name)
+	if _, ok := currentConfig().Commands[name]; ok {
+		msg += ", but the config file's !" + name + " takes precedence"
+	}
+	return Reply(ctx, cmd, msg)
+}
+
+func deleteCommandCmd(ctx context.Context, cmd Params) error {
+	if !cmd.IsModerator() {
+		return nil
+	}
+	if len(cmd.CommandArgs) != 1 {
+		return Reply(ctx, cmd, "usage: !delcom <name>")
+	}
+
+	name := customCommandName(cmd.CommandArgs[0])
+	cc, err := GetCustomCommand(name)
+	if err != nil {
+		return err
+	}
+	if cc == nil {
+		return Reply(ctx, cmd, fmt.Sprintf("!%s is not a custom command", name))
+	}
+
+	if err := DeleteCustomCommand(name); err != nil {
+		return err
+	}
+	return Reply(ctx, cmd, fmt.Sprintf("Deleted !%s", name))
+}
+
+// aliasCommandCmd adds a custom command that runs another command:
+// !aliascom dc discord
+func aliasCommandCmd(ctx context.Context, cmd Params) error {
+	if !cmd.IsModerator() {
+		return nil
+	}
+	if len(cmd.CommandArgs) != 2 {
+		return Reply(ctx, cmd, "usage: !aliascom <alias> <command>")
+	}
+
+	name := customCommandName(cmd.CommandArgs[0])
+	target := customCommandName(cmd.CommandArgs[1])
+	if commandExists(name) {
+		return Reply(ctx, cmd, fmt.Sprintf("!%s already exists", name))
+	}
+	if _, ok := lookupCommand(currentConfig(), target); !ok {
+		return Reply(ctx, cmd, fmt.Sprintf("!%s does not exist", target))
+	}
+
+	err := SaveCustomCommand(CustomCommand{
+		Name:      name,
+		Alias:     target,
+		CreatedBy: cmd.UserName,
+	})
+	if err != nil {
+		return err
+	}
+
+	return Reply(ctx, cmd, fmt.Sprintf("!%s now runs !%s", name, target))
+}
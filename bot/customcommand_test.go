@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCustomCommands(t *testing.T) {
+	useTestConfig(t, `{"commands": {"lurk": {"name": "lurk", "enabled": true, "offline": true}}}`)
+	openTestDatabase(t)
+
+	mod := map[string]int{"moderator": 1}
+	// Run in order against the same database
+	steps := []struct {
+		command string
+		badges  map[string]int
+		reply   string
+	}{
+		{command: "addcom discord Join us"},
+		{command: "addcom !Discord Join us at {{args.0}}", badges: mod, reply: "Added !discord"},
+		{command: "addcom discord Again", badges: mod, reply: "!discord already exists"},
+		{command: "addcom lurk Config commands win", badges: mod, reply: "!lurk already exists"},
+		{command: "addcom help Builtins win", badges: mod, reply: "!help already exists"},
+		{command: "addcom discord", badges: mod, reply: "usage: !addcom <name> <response>"},
+		{command: "aliascom dc discord", badges: mod, reply: "!dc now runs !discord"},
+		{command: "aliascom d dc", badges: mod, reply: "!d now runs !dc"},
+		{command: "aliascom nope missing", badges: mod, reply: "!missing does not exist"},
+		{command: "editcom discord Come chat", badges: mod, reply: "Updated !discord"},
+		{command: "editcom missing Nothing", badges: mod, reply: "!missing is not a custom command"},
+		{command: "delcom missing", badges: mod, reply: "!missing is not a custom command"},
+	}
+
+	for _, s := range steps {
+		args := strings.Fields(s.command)
+		err := ExecuteCommand(context.Background(), Params{Provider: "test", UserID: "1", UserName: "erik", UserBadges: s.badges, Command: args[0], CommandArgs: args[1:]})
+		if err != nil {
+			t.Fatalf("%s: %s", s.command, err)
+		}
+
+		var want []string
+		if s.reply != "" {
+			want = []string{s.reply}
+		}
+		if got := replies.take(); !sameItems(got, want) {
+			t.Errorf("%s: replied %q, want %q", s.command, got, want)
+		}
+	}
+
+	// Aliases, and aliases of aliases, run the command they point at
+	for _, name := range []string{"discord", "dc", "d"} {
+		c, ok := lookupCommand(currentConfig(), name)
+		if !ok {
+			t.Fatalf("!%s not found", name)
+		}
+		if c.Name != "discord" || c.Actions[0].Args["message"] != "Come chat" {
+			t.Errorf("!%s is %s saying %q", name, c.Name, c.Actions[0].Args["message"])
+		}
+	}
+
+	want := []string{"d", "dc", "discord", "lurk"}
+	if got := commandNames(); !sameItems(got, want) {
+		t.Errorf("command names %v, want %v", got, want)
+	}
+
+	ExecuteCommand(context.Background(), Params{Provider: "test", UserBadges: mod, Command: "delcom", CommandArgs: []string{"discord"}})
+	if _, ok := lookupCommand(currentConfig(), "dc"); ok {
+		t.Error("!dc still runs after deleting the command it points at")
+	}
+}
+
+func TestLookupCommandAliasLoop(t *testing.T) {
+	useTestConfig(t, `{}`)
+	openTestDatabase(t)
+
+	SaveCustomCommand(CustomCommand{Name: "a", Alias: "b"})
+	SaveCustomCommand(CustomCommand{Name: "b", Alias: "a"})
+
+	if _, ok := lookupCommand(currentConfig(), "a"); ok {
+		t.Error("found a command for an alias loop")
+	}
+}
@@ -12,9 +12,14 @@ var db *bbolt.DB
 var USER_BUCKET = []byte("Users")
 var FOLLOWER_BUCKET = []byte("Followers")
 var COUNTER_BUCKET = []byte("Counters")
-
-func IncrementCounter(counter string) (current uint64) {
-	db.Update(func(tx *bbolt.Tx) error {
+var CUSTOM_COMMAND_BUCKET = []byte("CustomCommands")
+var QUOTE_BUCKET = []byte("Quotes")
+var AUDIT_BUCKET = []byte("Audit")
+
+// updateCounter applies f to the counter's current value and stores the
+// result.
+func updateCounter(counter string, f func(uint64) uint64) (current uint64) {
+	err := db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(COUNTER_BUCKET)
 		v := b.Get([]byte(counter))
 
@@ -23,15 +28,54 @@ func IncrementCounter(counter string) (current uint64) {
 				return err
 			}
 		}
-		current++
+		current = f(current)
 
 		j, err := json.Marshal(current)
 		if err != nil {
 			return err
 		}
-		b.Put([]byte(counter), j)
+		return b.Put([]byte(counter), j)
+	})
 
-		return nil
+	if err == nil {
+		Publish(CounterChangedEvent{Name: counter, Value: current})
+	}
+	return
+}
+
+func IncrementCounter(counter string) uint64 {
+	return updateCounter(counter, func(v uint64) uint64 {
+		return v + 1
+	})
+}
+
+// DecrementCounter lowers the counter by one, stopping at zero.
+func DecrementCounter(counter string) uint64 {
+	return updateCounter(counter, func(v uint64) uint64 {
+		if v == 0 {
+			return 0
+		}
+		return v - 1
+	})
+}
+
+func SetCounter(counter string, value uint64) uint64 {
+	return updateCounter(counter, func(uint64) uint64 {
+		return value
+	})
+}
+
+func ResetCounter(counter string) uint64 {
+	return SetCounter(counter, 0)
+}
+
+func GetCounter(counter string) (current uint64) {
+	db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(COUNTER_BUCKET).Get([]byte(counter))
+		if len(v) == 0 {
+			return nil
+		}
+		return json.Unmarshal(v, &current)
 	})
 
 	return
@@ -53,7 +97,35 @@ func ListCounters() []string {
 	return counters
 }
 
+// InitDatabase opens the database and starts keeping the followers up to
+// date and the audit log pruned.
 func InitDatabase(file string, mode os.FileMode) error {
+	if err := OpenDatabase(file, mode); err != nil {
+		return err
+	}
+
+	go func() {
+		sync := func() {
+			if err := UpdateFollowers(); err != nil {
+				Log.WithError(err).Error("Failed to sync followers")
+			}
+		}
+
+		sync()
+		t := time.NewTicker(5 * time.Minute)
+		for range t.C {
+			sync()
+		}
+	}()
+
+	go pruneAuditPeriodically()
+
+	return nil
+}
+
+// OpenDatabase opens the database without talking to Twitch, for CLI
+// commands that only work with stored data.
+func OpenDatabase(file string, mode os.FileMode) error {
 	var err error
 	db, err = bbolt.Open(file, mode, &bbolt.Options{Timeout: 1 * time.Second})
 	if err != nil {
@@ -84,18 +156,28 @@ func InitDatabase(file string, mode os.FileMode) error {
 		return err
 	}
 
-	// Commit the transaction and check for error.
-	if err := tx.Commit(); err != nil {
+	_, err = tx.CreateBucketIfNotExists(CUSTOM_COMMAND_BUCKET)
+	if err != nil {
 		return err
 	}
 
-	go func() {
-		UpdateFollowers()
-		t := time.NewTicker(5 * time.Minute)
-		for range t.C {
-			UpdateFollowers()
-		}
-	}()
+	_, err = tx.CreateBucketIfNotExists(QUOTE_BUCKET)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	_, err = tx.CreateBucketIfNotExists(AUDIT_BUCKET)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction and check for error.
+	return tx.Commit()
+}
+
+func CloseDatabase() error {
+	if db == nil {
+		return nil
+	}
+	return db.Close()
 }
@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration that can be written in config as a string
+// such as "30s" or "5m", or as a plain number of seconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("Invalid duration %s", string(b))
+	}
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// formatRemaining rounds a remaining duration up to the next whole second so
+// chat never sees "0s" or "4.123456789s".
+func formatRemaining(d time.Duration) string {
+	if d <= 0 {
+		return "0s"
+	}
+	return (d + time.Second - 1).Truncate(time.Second).String()
+}
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: `"30s"`, want: 30 * time.Second},
+		{in: `"1h30m"`, want: 90 * time.Minute},
+		{in: `"250ms"`, want: 250 * time.Millisecond},
+		{in: `45`, want: 45 * time.Second},
+		{in: `1.5`, want: 1500 * time.Millisecond},
+		{in: `0`, want: 0},
+		{in: `null`, want: 0},
+		{in: `"5 minutes"`, err: true},
+		{in: `""`, err: true},
+		{in: `true`, err: true},
+		{in: `["30s"]`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			// Starts non-zero so null resetting it is checked
+			d := Duration(time.Hour)
+			err := json.Unmarshal([]byte(tt.in), &d)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if time.Duration(d) != tt.want {
+				t.Errorf("got %s, want %s", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationMarshalJSON(t *testing.T) {
+	j, err := json.Marshal(Duration(90 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j) != `"1m30s"` {
+		t.Errorf("got %s, want \"1m30s\"", j)
+	}
+
+	var d Duration
+	if err := json.Unmarshal(j, &d); err != nil || d != Duration(90*time.Second) {
+		t.Errorf("round trip gave %s, %v", d, err)
+	}
+}
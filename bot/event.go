@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ChatMessageEvent is published for every chat message, commands included.
+type ChatMessageEvent struct {
+	User    *User
+	Message ChatMessage
+	Command bool
+}
+
+// CommandExecutedEvent is published after a command ran, or failed to.
+// Points is what the user was charged, failed commands are refunded.
+type CommandExecutedEvent struct {
+	Params   Params
+	Points   uint64
+	Err      error
+	Duration time.Duration
+}
+
+// TriggerExecutedEvent is published after a configured trigger ran. Triggers
+// fired without being configured, or while on cooldown, aren't published.
+type TriggerExecutedEvent struct {
+	Name     string
+	Params   Params
+	Err      error
+	Duration time.Duration
+}
+
+// UserNoticeEvent is published for chat events such as subs and raids.
+type UserNoticeEvent struct {
+	Event ChatEvent
+}
+
+type StreamStartedEvent struct{}
+
+type StreamStoppedEvent struct{}
+
+type SceneChangedEvent struct {
+	Old string
+	New string
+}
+
+// FollowerGainedEvent is published for each new Twitch follower found when
+// the followers are synced.
+type FollowerGainedEvent struct {
+	UserID     string
+	UserName   string
+	FollowedAt time.Time
+}
+
+type CounterChangedEvent struct {
+	Name  string
+	Value uint64
+}
+
+var busLock sync.RWMutex
+var subscribers = make(map[reflect.Type][]reflect.Value)
+
+// Subscribe registers handler for one type of event. handler must be a func
+// taking a single event, e.g. func(bot.SceneChangedEvent). Handlers run on
+// the publishing goroutine in the order they subscribed, so anything slow
+// should start its own goroutine.
+func Subscribe(handler interface{}) {
+	v := reflect.ValueOf(handler)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 0 {
+		panic(fmt.Sprintf("bot.Subscribe: handler must be a func taking one event, got %s", t))
+	}
+
+	busLock.Lock()
+	defer busLock.Unlock()
+
+	subscribers[t.In(0)] = append(subscribers[t.In(0)], v)
+}
+
+// Publish calls every handler subscribed to the event's type.
+func Publish(event interface{}) {
+	busLock.RLock()
+	handlers := subscribers[reflect.TypeOf(event)]
+	busLock.RUnlock()
+
+	args := []reflect.Value{reflect.ValueOf(event)}
+	for _, h := range handlers {
+		h.Call(args)
+	}
+}
+
+// Configured triggers are one subscriber among others.
+func init() {
+	Subscribe(func(e ChatMessageEvent) {
+		m := e.Message
+		if e.Command || !m.MainChannel || m.Ignored || m.Text == "" {
+			return
+		}
+
+		cmd := Params{
+			Provider:   m.Provider,
+			Channel:    m.Channel,
+			UserID:     m.User.ID,
+			UserName:   m.User.DisplayName,
+			UserBadges: m.User.Badges,
+		}
+		RunTrigger(m.Provider+"::Chat", cmd)
+		RunChatTriggers(cmd, m.Text)
+	})
+
+	Subscribe(func(e UserNoticeEvent) {
+		RunTrigger(fmt.Sprintf("%s::%s", e.Event.Provider, e.Event.Name), Params{
+			Provider: e.Event.Provider,
+			Channel:  e.Event.Channel,
+			UserID:   e.Event.User.ID,
+			UserName: e.Event.User.DisplayName,
+			Payload:  e.Event.Payload,
+		})
+	})
+
+	Subscribe(func(e FollowerGainedEvent) {
+		RunTrigger("twitch::Follow", Params{
+			Provider: "twitch",
+			Channel:  getMainChannel(),
+			UserID:   e.UserID,
+			UserName: e.UserName,
+			Payload: map[string]string{
+				"followedAt": e.FollowedAt.Format(time.RFC3339),
+			},
+		})
+	})
+
+	Subscribe(func(e StreamStartedEvent) {
+		for _, name := range currentConfig().StreamCounters {
+			ResetCounter(name)
+		}
+		fireStreamingTrigger("bot::StreamStarted", true)
+	})
+
+	Subscribe(func(e StreamStoppedEvent) {
+		fireStreamingTrigger("bot::StreamStopped", false)
+	})
+}
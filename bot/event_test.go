@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Only this test publishes these, handlers can't be unsubscribed
+type orderEvent struct {
+	N int
+}
+
+type otherEvent struct{}
+
+func TestPublishOrder(t *testing.T) {
+	var got recorder
+	Subscribe(func(e orderEvent) {
+		got.add("first " + strconv.Itoa(e.N))
+	})
+	Subscribe(func(e orderEvent) {
+		got.add("second " + strconv.Itoa(e.N))
+	})
+	Subscribe(func(e otherEvent) {
+		got.add("other")
+	})
+
+	Publish(orderEvent{N: 1})
+	Publish(orderEvent{N: 2})
+
+	want := []string{"first 1", "second 1", "first 2", "second 2"}
+	if items := got.take(); !sameItems(items, want) {
+		t.Errorf("got %v, want %v", items, want)
+	}
+}
+
+func TestSubscribeBadHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler interface{}
+	}{
+		{name: "not a func", handler: "hello"},
+		{name: "no event", handler: func() {}},
+		{name: "two events", handler: func(orderEvent, otherEvent) {}},
+		{name: "returns", handler: func(orderEvent) error { return nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			Subscribe(tt.handler)
+		})
+	}
+}
+
+func TestEventTriggers(t *testing.T) {
+	tests := []struct {
+		name  string
+		event interface{}
+		ran   []string
+	}{
+		{
+			name:  "chat",
+			event: ChatMessageEvent{Message: ChatMessage{Provider: "test", Text: "hello", MainChannel: true, User: ChatUser{DisplayName: "erik"}}},
+			ran:   []string{"chat erik"},
+		},
+		{
+			name:  "command",
+			event: ChatMessageEvent{Message: ChatMessage{Provider: "test", Text: "!hello", MainChannel: true}, Command: true},
+		},
+		{
+			name:  "other channel",
+			event: ChatMessageEvent{Message: ChatMessage{Provider: "test", Text: "hello"}},
+		},
+		{
+			name:  "user notice",
+			event: UserNoticeEvent{Event: ChatEvent{Provider: "test", Name: "raid", User: ChatUser{DisplayName: "aaron"}, Payload: map[string]string{"viewers": "42"}}},
+			ran:   []string{"raid aaron 42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, `{"triggers": {
+				"test::Chat": {"actions": [{"name": "test::Record", "args": {"name": "chat {{user}}"}}]},
+				"test::raid": {"actions": [{"name": "test::Record", "args": {"name": "raid {{user}} {{payload.viewers}}"}}]}
+			}}`)
+
+			Publish(tt.event)
+			WaitForCommands(time.Second)
+
+			if got := ranActions.take(); !sameItems(got, tt.ran) {
+				t.Errorf("ran %v, want %v", got, tt.ran)
+			}
+		})
+	}
+}
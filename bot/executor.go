@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var defaultCommandTimeout = 2 * time.Minute
+
+// executor runs commands and triggers on their own goroutines so slow actions
+// don't block chat. Every run gets a context derived from a shared root which
+// CancelAll replaces, cancelling everything in flight.
+type executor struct {
+	lock   sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var runner = newExecutor()
+
+func newExecutor() *executor {
+	e := &executor{}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	return e
+}
+
+func (e *executor) run(f func(ctx context.Context)) {
+	e.lock.Lock()
+	ctx := e.ctx
+	e.wg.Add(1)
+	e.lock.Unlock()
+
+	go func() {
+		defer e.wg.Done()
+		f(ctx)
+	}()
+}
+
+func (e *executor) cancelAll() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.cancel()
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+}
+
+// RunCommand executes the command on its own goroutine.
+func RunCommand(cmd Params) {
+	runner.run(func(ctx context.Context) {
+		// ExecuteCommand logs the outcome
+		ExecuteCommand(ctx, cmd)
+	})
+}
+
+// RunTrigger executes the trigger on its own goroutine.
+func RunTrigger(name string, cmd Params) {
+	runner.run(func(ctx context.Context) {
+		if err := ExecuteTrigger(ctx, name, cmd); err != nil {
+			Log.WithError(err).WithFields(paramsFields(cmd)).WithField("trigger", name).Error("Trigger failed")
+		}
+	})
+}
+
+// CancelAll cancels every command and trigger started with RunCommand or
+// RunTrigger that is still running.
+func CancelAll() {
+	runner.cancelAll()
+}
+
+// WaitForCommands blocks until all running commands and triggers finish or
+// the timeout passes. It returns false if the timeout was hit.
+func WaitForCommands(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		runner.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func commandTimeout(d Duration) time.Duration {
+	if d > 0 {
+		return time.Duration(d)
+	}
+	if t := currentConfig().CommandTimeout; t > 0 {
+		return time.Duration(t)
+	}
+	return defaultCommandTimeout
+}
+
+// Sleep pauses for d or until ctx is cancelled.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
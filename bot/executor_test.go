@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExecutorCancelAll(t *testing.T) {
+	e := newExecutor()
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	e.run(func(ctx context.Context) {
+		close(started)
+		cancelled <- Sleep(ctx, time.Minute)
+	})
+	<-started
+	e.cancelAll()
+
+	select {
+	case err := <-cancelled:
+		if err != context.Canceled {
+			t.Errorf("got %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the running command wasn't cancelled")
+	}
+
+	// Runs started afterwards aren't cancelled
+	next := make(chan error, 1)
+	e.run(func(ctx context.Context) {
+		next <- ctx.Err()
+	})
+	if err := <-next; err != nil {
+		t.Errorf("got %v for a run after cancelling", err)
+	}
+	e.wg.Wait()
+}
+
+func TestCommandTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		d      Duration
+		want   time.Duration
+	}{
+		{name: "default", config: `{}`, want: defaultCommandTimeout},
+		{name: "config", config: `{"commandTimeout": "30s"}`, want: 30 * time.Second},
+		{name: "command", config: `{"commandTimeout": "30s"}`, d: Duration(5 * time.Second), want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, tt.config)
+			if got := commandTimeout(tt.d); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("got %v sleeping", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Sleep(ctx, time.Minute); err != context.Canceled {
+		t.Errorf("got %v with a cancelled context, want %v", err, context.Canceled)
+	}
+}
+
+func TestWaitForCommands(t *testing.T) {
+	release := make(chan struct{})
+	runner.run(func(ctx context.Context) {
+		<-release
+	})
+
+	if WaitForCommands(10 * time.Millisecond) {
+		t.Error("didn't time out while a command was running")
+	}
+	close(release)
+	if !WaitForCommands(time.Second) {
+		t.Error("timed out after the command finished")
+	}
+}
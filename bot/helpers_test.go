@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects what happened during a test, from whichever goroutine it
+// happened on.
+type recorder struct {
+	lock  sync.Mutex
+	items []string
+}
+
+func (r *recorder) add(s string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.items = append(r.items, s)
+}
+
+// take returns what was recorded and forgets it.
+func (r *recorder) take() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	items := r.items
+	r.items = nil
+	return items
+}
+
+// The "name" arg of every test::Record action run
+var ranActions recorder
+
+// The name of every command a CommandExecutedEvent was published for
+var executedCommands recorder
+
+// What the bot said through the "test" chat provider, and in which channels
+var replies recorder
+var replyChannels recorder
+
+// testChat records what the bot says, for commands whose Provider is "test".
+type testChat struct{}
+
+func (testChat) Name() string        { return "test" }
+func (testChat) MainChannel() string { return "main" }
+
+func (testChat) Connect(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (testChat) Say(channel string, message string) error {
+	replies.add(message)
+	replyChannels.add(channel)
+	return nil
+}
+
+func (testChat) OnMessage(func(ChatMessage)) {}
+func (testChat) OnEvent(func(ChatEvent))     {}
+
+func init() {
+	RegisterModule(Module{
+		Name: "test",
+		Actions: map[string]ActionFunc{
+			"Record": func(ctx context.Context, a Action, cmd Params) error {
+				ranActions.add(a.Args["name"])
+				return nil
+			},
+			"Fail": func(ctx context.Context, a Action, cmd Params) error {
+				return errors.New("failed on purpose")
+			},
+			// Records its "name" arg after waiting for its "duration" arg,
+			// unless the context is done first
+			"Sleep": func(ctx context.Context, a Action, cmd Params) error {
+				d, err := time.ParseDuration(a.Args["duration"])
+				if err != nil {
+					return err
+				}
+				if err := Sleep(ctx, d); err != nil {
+					return err
+				}
+				ranActions.add(a.Args["name"])
+				return nil
+			},
+		},
+	})
+
+	RegisterChatProvider(testChat{})
+
+	Subscribe(func(e CommandExecutedEvent) {
+		executedCommands.add(e.Params.Command)
+	})
+}
+
+// useTestConfig parses the config and makes it active, with no cooldowns
+// started, until the test ends.
+func useTestConfig(t *testing.T, s string) *Config {
+	c, err := ParseConfig(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configLock.Lock()
+	old := config
+	config = c
+	configLock.Unlock()
+
+	oldCooldowns := cooldowns
+	cooldowns = newCooldownTracker()
+	ranActions.take()
+	executedCommands.take()
+	replies.take()
+	replyChannels.take()
+
+	t.Cleanup(func() {
+		configLock.Lock()
+		config = old
+		configLock.Unlock()
+		cooldowns = oldCooldowns
+	})
+	return c
+}
+
+// useStreaming sets whether the stream is live until the test ends.
+func useStreaming(t *testing.T, streaming bool) {
+	oldStreaming, oldScene := Status.Streaming(), Status.Scene()
+	RestoreStatus(streaming, oldScene)
+	t.Cleanup(func() {
+		RestoreStatus(oldStreaming, oldScene)
+	})
+}
+
+// openTestDatabase opens a database in a temporary directory, and forgets
+// the cached users of earlier tests. It's closed and removed when the test
+// ends, leaving later tests without a database.
+func openTestDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := OpenDatabase(filepath.Join(dir, "bot.db"), 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	users.Purge()
+
+	t.Cleanup(func() {
+		CloseDatabase()
+		db = nil
+		users.Purge()
+		os.RemoveAll(dir)
+	})
+}
+
+// forgetModule unregisters the module and its actions when the test ends, so
+// it can register again when the test is run more than once.
+func forgetModule(t *testing.T, name string) {
+	t.Cleanup(func() {
+		registryLock.Lock()
+		defer registryLock.Unlock()
+
+		for i := range modules {
+			if modules[i].Name == name {
+				for action := range modules[i].Actions {
+					delete(registeredActions, name+"::"+action)
+				}
+				modules = append(modules[:i], modules[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// sameItems reports whether got and want hold the same strings in the same
+// order, treating nil and empty alike.
+func sameItems(got []string, want []string) bool {
+	if len(got) == 0 && len(want) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func sameMap(got map[string]string, want map[string]string) bool {
+	if len(got) == 0 && len(want) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(got, want)
+}
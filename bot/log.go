@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log is the logger every package logs through. Attach context with fields
+// rather than formatting it into the message, e.g.
+// Log.WithField("module", name).Info("Module initialized"). Secrets resolved
+// from module configs are redacted.
+var Log = &logrus.Logger{
+	Out:       os.Stderr,
+	Formatter: &redactingFormatter{&logrus.TextFormatter{FullTimestamp: true}},
+	Hooks:     make(logrus.LevelHooks),
+	Level:     logrus.InfoLevel,
+}
+
+// LogConfig sets how the bot logs. Level is one of debug, info, warn or
+// error, and Format is text or json.
+type LogConfig struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+}
+
+// parseLogLevel accepts only the levels the bot logs at, not the rest of
+// logrus's (trace, fatal and panic).
+func parseLogLevel(level string) (logrus.Level, error) {
+	switch level {
+	case "debug", "info", "warn", "error":
+		return logrus.ParseLevel(level)
+	}
+	return 0, fmt.Errorf("Log level '%s' must be one of debug, info, warn or error", level)
+}
+
+func (c LogConfig) validate() error {
+	if c.Level != "" {
+		if _, err := parseLogLevel(c.Level); err != nil {
+			return err
+		}
+	}
+
+	switch c.Format {
+	case "", "text", "json":
+		return nil
+	}
+	return fmt.Errorf("Log format '%s' must be text or json", c.Format)
+}
+
+var logLock sync.Mutex
+var logLevelOverride string
+
+// OverrideLogLevel sets a level that wins over the config's, e.g. from a
+// command line flag.
+func OverrideLogLevel(level string) error {
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	logLock.Lock()
+	defer logLock.Unlock()
+
+	logLevelOverride = level
+	Log.SetLevel(l)
+	return nil
+}
+
+// configureLogging applies the config's log settings. c must have been
+// validated.
+func configureLogging(c LogConfig) {
+	logLock.Lock()
+	defer logLock.Unlock()
+
+	level := c.Level
+	if logLevelOverride != "" {
+		level = logLevelOverride
+	}
+	if level == "" {
+		level = "info"
+	}
+	if l, err := parseLogLevel(level); err == nil {
+		Log.SetLevel(l)
+	}
+
+	if c.Format == "json" {
+		Log.SetFormatter(&redactingFormatter{&logrus.JSONFormatter{}})
+	} else {
+		Log.SetFormatter(&redactingFormatter{&logrus.TextFormatter{FullTimestamp: true}})
+	}
+}
+
+// paramsFields are the fields logged for anything run on behalf of a user.
+func paramsFields(cmd Params) logrus.Fields {
+	f := logrus.Fields{
+		"command": cmd.Command,
+	}
+	if cmd.UserName != "" {
+		f["user"] = cmd.UserName
+	}
+	if cmd.Provider != "" {
+		f["provider"] = cmd.Provider
+	}
+	return f
+}
@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		c    LogConfig
+		err  bool
+	}{
+		{name: "defaults", c: LogConfig{}},
+		{name: "debug", c: LogConfig{Level: "debug"}},
+		{name: "info", c: LogConfig{Level: "info"}},
+		{name: "warn", c: LogConfig{Level: "warn"}},
+		{name: "error", c: LogConfig{Level: "error"}},
+		{name: "json", c: LogConfig{Level: "info", Format: "json"}},
+		{name: "text", c: LogConfig{Format: "text"}},
+		{name: "trace", c: LogConfig{Level: "trace"}, err: true},
+		{name: "fatal", c: LogConfig{Level: "fatal"}, err: true},
+		{name: "panic", c: LogConfig{Level: "panic"}, err: true},
+		{name: "unknown level", c: LogConfig{Level: "loud"}, err: true},
+		{name: "unknown format", c: LogConfig{Format: "xml"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.c.validate()
+			if tt.err && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestOverrideLogLevel(t *testing.T) {
+	t.Cleanup(func() {
+		logLevelOverride = ""
+		Log.SetLevel(logrus.InfoLevel)
+	})
+
+	if err := OverrideLogLevel("trace"); err == nil {
+		t.Error("expected an error for trace")
+	}
+	if err := OverrideLogLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	if Log.GetLevel() != logrus.DebugLevel {
+		t.Errorf("got level %s, want debug", Log.GetLevel())
+	}
+
+	// The override wins over the config
+	configureLogging(LogConfig{Level: "error"})
+	if Log.GetLevel() != logrus.DebugLevel {
+		t.Errorf("got level %s after configuring, want debug", Log.GetLevel())
+	}
+}
+
+func TestParamsFields(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  Params
+		want logrus.Fields
+	}{
+		{name: "chat", cmd: Params{Command: "hi", UserName: "erik", Provider: "twitch"}, want: logrus.Fields{"command": "hi", "user": "erik", "provider": "twitch"}},
+		{name: "timer", cmd: Params{Command: "hydrate"}, want: logrus.Fields{"command": "hydrate"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paramsFields(tt.cmd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandLogging(t *testing.T) {
+	useTestConfig(t, `{"commands": {
+		"hi": {"enabled": true, "offline": true, "actions": [{"name": "test::Record"}]},
+		"broken": {"enabled": true, "offline": true, "actions": [{"name": "test::Fail"}]}
+	}}`)
+
+	var out bytes.Buffer
+	oldOut := Log.Out
+	Log.SetOutput(&out)
+	configureLogging(LogConfig{Level: "debug", Format: "json"})
+	t.Cleanup(func() {
+		Log.SetOutput(oldOut)
+		configureLogging(LogConfig{})
+	})
+
+	tests := []struct {
+		command string
+		level   string
+		msg     string
+	}{
+		{command: "hi", level: "info", msg: "Command executed"},
+		{command: "broken", level: "error", msg: "Command failed"},
+		{command: "nope", level: "debug", msg: "Command not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			out.Reset()
+			ExecuteCommand(context.Background(), Params{Provider: "test", UserName: "erik", Command: tt.command})
+
+			// The last line, actions log too
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+				t.Fatal(err)
+			}
+			if entry["level"] != tt.level || entry["msg"] != tt.msg {
+				t.Errorf("got %v %q, want %s %q", entry["level"], entry["msg"], tt.level, tt.msg)
+			}
+			if entry["command"] != tt.command || entry["user"] != "erik" || entry["provider"] != "test" || entry["duration"] == nil {
+				t.Errorf("got fields %v", entry)
+			}
+		})
+	}
+}
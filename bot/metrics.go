@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default Prometheus registry, which the
+// http package serves on /metrics.
+var (
+	commandsExecuted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "erikbotdev",
+		Name:      "commands_executed_total",
+		Help:      "Commands executed, by command and result.",
+	}, []string{"command", "result"})
+
+	actionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "erikbotdev",
+		Name:      "action_duration_seconds",
+		Help:      "How long actions took to run, by action and result.",
+		Buckets:   []float64{.005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"action", "result"})
+
+	triggersFired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "erikbotdev",
+		Name:      "triggers_fired_total",
+		Help:      "Configured triggers that ran, by trigger.",
+	}, []string{"trigger"})
+
+	chatMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "erikbotdev",
+		Name:      "chat_messages_total",
+		Help:      "Chat messages processed, by provider.",
+	}, []string{"provider"})
+
+	followerSyncDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "erikbotdev",
+		Name:      "follower_sync_duration_seconds",
+		Help:      "How long syncing the Twitch followers took.",
+		Buckets:   prometheus.ExponentialBuckets(.1, 2, 10),
+	})
+)
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// commandLabel is the command label for a command that ran. Counters are
+// named by whoever types them, so they share a label.
+func commandLabel(name string) string {
+	switch {
+	case strings.HasSuffix(name, "++"):
+		return "counter_increment"
+	case strings.HasSuffix(name, "--"):
+		return "counter_decrement"
+	}
+
+	if _, ok := builtinCommands[name]; ok {
+		return name
+	}
+	if _, ok := lookupCommand(currentConfig(), name); ok {
+		return name
+	}
+	return "unknown"
+}
+
+func init() {
+	Subscribe(func(e CommandExecutedEvent) {
+		// Anyone can type any command, only label the ones that exist so
+		// chat can't blow up the number of series
+		if _, ok := e.Err.(*CommandNotFoundError); ok {
+			commandsExecuted.WithLabelValues("unknown", "not_found").Inc()
+			return
+		}
+		commandsExecuted.WithLabelValues(commandLabel(e.Params.Command), resultLabel(e.Err)).Inc()
+	})
+
+	Subscribe(func(e TriggerExecutedEvent) {
+		triggersFired.WithLabelValues(e.Name).Inc()
+	})
+
+	Subscribe(func(e ChatMessageEvent) {
+		chatMessages.WithLabelValues(e.Message.Provider).Inc()
+	})
+}
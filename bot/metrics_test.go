@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCommandLabel(t *testing.T) {
+	useTestConfig(t, `{"commands": {"hydrate": {"enabled": true}}}`)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "hydrate", want: "hydrate"},
+		{name: "help", want: "help"},
+		{name: "deaths++", want: "counter_increment"},
+		{name: "deaths--", want: "counter_decrement"},
+		{name: "whatever", want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commandLabel(tt.name); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandMetrics(t *testing.T) {
+	useTestConfig(t, `{"commands": {
+		"hydrate": {"enabled": true, "offline": true, "actions": [{"name": "test::Record"}]},
+		"broken": {"enabled": true, "offline": true, "actions": [{"name": "test::Fail"}]}
+	}}`)
+
+	tests := []struct {
+		command string
+		labels  []string
+	}{
+		{command: "hydrate", labels: []string{"hydrate", "ok"}},
+		{command: "broken", labels: []string{"broken", "error"}},
+		{command: "nope", labels: []string{"unknown", "not_found"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			counter := commandsExecuted.WithLabelValues(tt.labels...)
+			before := testutil.ToFloat64(counter)
+
+			ExecuteCommand(context.Background(), Params{UserID: "1", Command: tt.command})
+
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("counted %v times, want once", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ModuleStatus is an enabled module's health.
+type ModuleStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+const moduleWatchInterval = 30 * time.Second
+
+// Modules whose Init failed, keyed by name. Their actions fail until
+// WatchModules manages to initialize them.
+var moduleLock sync.Mutex
+var failedModules = make(map[string]error)
+var initializedModules []string
+
+// InitModules initializes the enabled modules with their config. A module
+// that fails to initialize is logged and left out rather than stopping the
+// bot, WatchModules keeps trying to initialize it.
+func InitModules() {
+	c := currentConfig()
+	for _, m := range registeredModules() {
+		if c.moduleEnabled(m.Name) {
+			initModule(c, m)
+		}
+	}
+}
+
+func initModule(c *Config, m Module) error {
+	if m.Init != nil {
+		start := time.Now()
+		if err := m.Init(c.ModuleConfig[m.Name]); err != nil {
+			moduleLock.Lock()
+			failedModules[m.Name] = err
+			moduleLock.Unlock()
+
+			Log.WithError(err).WithField("module", m.Name).Error("Failed to initialize module, its actions are disabled until it recovers")
+			return err
+		}
+
+		Log.WithFields(logrus.Fields{
+			"module":   m.Name,
+			"duration": time.Since(start).String(),
+		}).Info("Module initialized")
+	}
+
+	moduleLock.Lock()
+	delete(failedModules, m.Name)
+	initializedModules = append(initializedModules, m.Name)
+	moduleLock.Unlock()
+	return nil
+}
+
+// moduleError returns why the module's actions can't run, if they can't.
+func moduleError(name string) error {
+	moduleLock.Lock()
+	defer moduleLock.Unlock()
+
+	if err, ok := failedModules[name]; ok {
+		return fmt.Errorf("Module %s failed to initialize: %s", name, err)
+	}
+	return nil
+}
+
+// ModuleHealth reports the health of every enabled module.
+func ModuleHealth() []ModuleStatus {
+	c := currentConfig()
+
+	var statuses []ModuleStatus
+	for _, m := range registeredModules() {
+		if !c.moduleEnabled(m.Name) {
+			continue
+		}
+
+		err := moduleError(m.Name)
+		if err == nil && m.Health != nil {
+			err = m.Health()
+		}
+
+		s := ModuleStatus{Name: m.Name, Healthy: err == nil}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// WatchModules retries initializing the modules that failed to, and the
+// Twitch API client, and reconnects the modules whose health check fails. It
+// blocks until ctx is done.
+func WatchModules(ctx context.Context) {
+	t := time.NewTicker(moduleWatchInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		c := currentConfig()
+		for _, m := range registeredModules() {
+			if !c.moduleEnabled(m.Name) {
+				continue
+			}
+
+			if moduleError(m.Name) != nil {
+				initModule(c, m)
+				continue
+			}
+
+			if m.Health == nil || m.Reconnect == nil {
+				continue
+			}
+			if err := m.Health(); err != nil {
+				log := Log.WithField("module", m.Name)
+				log.WithError(err).Warn("Module is unhealthy, reconnecting")
+
+				if err := m.Reconnect(ctx); err != nil {
+					log.WithError(err).Error("Failed to reconnect module")
+				} else {
+					log.Info("Module reconnected")
+				}
+			}
+		}
+
+		if c.moduleEnabled("twitch") && GetHelixClient() == nil {
+			if err := InitTwitchAPI(); err != nil {
+				Log.WithError(err).Warn("Failed to connect to the Twitch API")
+			} else {
+				Log.Info("Connected to the Twitch API")
+			}
+		}
+	}
+}
+
+// ShutdownModules shuts the initialized modules down, in the reverse of the
+// order they were initialized in.
+func ShutdownModules(ctx context.Context) {
+	moduleLock.Lock()
+	names := append([]string{}, initializedModules...)
+	moduleLock.Unlock()
+
+	for i := len(names) - 1; i >= 0; i-- {
+		m := getModule(names[i])
+		if m == nil || m.Shutdown == nil {
+			continue
+		}
+
+		if err := m.Shutdown(ctx); err != nil {
+			Log.WithError(err).WithField("module", m.Name).Error("Failed to shut down module")
+		} else {
+			Log.WithField("module", m.Name).Debug("Module shut down")
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// useModules registers the modules until the test ends, with no module
+// initialized or failed before them.
+func useModules(t *testing.T, mods ...Module) {
+	moduleLock.Lock()
+	oldFailed, oldInitialized := failedModules, initializedModules
+	failedModules = make(map[string]error)
+	initializedModules = nil
+	moduleLock.Unlock()
+
+	for _, m := range mods {
+		RegisterModule(m)
+		forgetModule(t, m.Name)
+	}
+
+	t.Cleanup(func() {
+		moduleLock.Lock()
+		failedModules, initializedModules = oldFailed, oldInitialized
+		moduleLock.Unlock()
+	})
+}
+
+func TestModuleHealth(t *testing.T) {
+	useModules(t,
+		Module{Name: "good", Init: func(json.RawMessage) error { return nil }, Health: func() error { return nil }},
+		Module{Name: "broken", Init: func(json.RawMessage) error { return errors.New("no route to host") }},
+		Module{Name: "sick", Health: func() error { return errors.New("disconnected") }},
+		Module{Name: "off", Init: func(json.RawMessage) error { return errors.New("shouldn't be initialized") }},
+	)
+	useTestConfig(t, `{"enabledModules": ["good", "broken", "sick"]}`)
+	InitModules()
+
+	want := map[string]ModuleStatus{
+		"good":   {Name: "good", Healthy: true},
+		"broken": {Name: "broken", Error: "Module broken failed to initialize: no route to host"},
+		"sick":   {Name: "sick", Error: "disconnected"},
+	}
+	got := make(map[string]ModuleStatus)
+	for _, s := range ModuleHealth() {
+		if _, ok := want[s.Name]; ok {
+			got[s.Name] = s
+		}
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("got %+v, want %+v", got[name], w)
+		}
+	}
+
+	if moduleError("off") != nil {
+		t.Error("a module that isn't enabled was initialized")
+	}
+}
+
+func TestShutdownModules(t *testing.T) {
+	var shutdown recorder
+	module := func(name string) Module {
+		return Module{
+			Name: name,
+			Shutdown: func(ctx context.Context) error {
+				shutdown.add(name)
+				return nil
+			},
+		}
+	}
+	useModules(t, module("first"), module("second"), module("off"), Module{Name: "third"})
+
+	useTestConfig(t, `{"enabledModules": ["first", "second", "third"]}`)
+	InitModules()
+	ShutdownModules(context.Background())
+
+	want := []string{"second", "first"}
+	if got := shutdown.take(); !sameItems(got, want) {
+		t.Errorf("shut down %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,508 @@
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PluginConfig runs an external program as a module. The program talks
+// JSON-RPC 2.0 over stdin and stdout, one message per line. It is sent an
+// "initialize" request with its moduleConfig and answers with the actions
+// and triggers it provides. Actions are run with "action" requests, and the
+// plugin can fire triggers with "trigger" notifications.
+type PluginConfig struct {
+	Command     string            `json:"command"`
+	Args        []string          `json:"args"`
+	Env         map[string]string `json:"env"`
+	Dir         string            `json:"dir"`
+	CallTimeout Duration          `json:"callTimeout"`
+}
+
+const defaultPluginCallTimeout = 30 * time.Second
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *uint64         `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+type pluginArg struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Enum     []string `json:"enum"`
+}
+
+type pluginAction struct {
+	Name string      `json:"name"`
+	Args []pluginArg `json:"args"`
+}
+
+// pluginManifest is a plugin's answer to "initialize".
+type pluginManifest struct {
+	Actions  []pluginAction `json:"actions"`
+	Triggers []string       `json:"triggers"`
+}
+
+// pluginActionResult is a plugin's answer to "action", which may be empty.
+type pluginActionResult struct {
+	Say string `json:"say"`
+}
+
+type pluginTrigger struct {
+	Name   string `json:"name"`
+	Params Params `json:"params"`
+}
+
+type plugin struct {
+	name   string
+	config PluginConfig
+
+	lock       sync.Mutex
+	stdin      io.WriteCloser
+	nextID     uint64
+	pending    map[uint64]chan *rpcMessage
+	registered bool
+	stopping   bool
+
+	// Writes to stdin block while the plugin isn't reading, so they're
+	// serialized by their own lock, leaving lock free for delivering
+	// responses
+	writeLock sync.Mutex
+
+	done chan struct{}
+}
+
+var pluginsLock sync.Mutex
+var plugins []*plugin
+var stopPlugins context.CancelFunc = func() {}
+
+func parseArgType(t string) ArgType {
+	switch t {
+	case "int":
+		return ArgInt
+	case "bool":
+		return ArgBool
+	case "duration":
+		return ArgDuration
+	case "enum":
+		return ArgEnum
+	}
+	return ArgString
+}
+
+func (p *plugin) callTimeout() time.Duration {
+	if p.config.CallTimeout > 0 {
+		return time.Duration(p.config.CallTimeout)
+	}
+	return defaultPluginCallTimeout
+}
+
+func (p *plugin) write(m rpcMessage) error {
+	m.JSONRPC = "2.0"
+	j, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	stdin := p.stdin
+	p.lock.Unlock()
+
+	if stdin == nil {
+		return fmt.Errorf("Plugin %s is not running", p.name)
+	}
+
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	_, err = stdin.Write(append(j, '\n'))
+	return err
+}
+
+func (p *plugin) notify(method string, params interface{}) error {
+	j, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return p.write(rpcMessage{Method: method, Params: j})
+}
+
+// call sends a request and waits for its result, giving up when ctx is done
+// or the plugin's call timeout passes.
+func (p *plugin) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	j, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	resp := make(chan *rpcMessage, 1)
+	p.lock.Lock()
+	p.nextID++
+	id := p.nextID
+	p.pending[id] = resp
+	p.lock.Unlock()
+
+	defer func() {
+		p.lock.Lock()
+		delete(p.pending, id)
+		p.lock.Unlock()
+	}()
+
+	if err := p.write(rpcMessage{ID: &id, Method: method, Params: j}); err != nil {
+		return err
+	}
+
+	timeout := time.NewTimer(p.callTimeout())
+	defer timeout.Stop()
+
+	select {
+	case m := <-resp:
+		if m == nil {
+			return fmt.Errorf("Plugin %s exited during %s", p.name, method)
+		}
+		if m.Error != nil {
+			return m.Error
+		}
+		if result != nil && len(m.Result) > 0 {
+			return json.Unmarshal(m.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		p.notify("cancel", map[string]uint64{"id": id})
+		return ctx.Err()
+	case <-timeout.C:
+		p.notify("cancel", map[string]uint64{"id": id})
+		return fmt.Errorf("Plugin %s: %s timed out after %s", p.name, method, p.callTimeout())
+	}
+}
+
+// read handles everything the plugin writes until it closes stdout, then
+// fails any calls still waiting. Lines that aren't valid messages are logged
+// and skipped.
+func (p *plugin) read(r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && len(line) == 0 {
+			if err != io.EOF {
+				Log.WithError(err).WithField("plugin", p.name).Error("Failed to read from plugin")
+			}
+			break
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var m rpcMessage
+		if err := json.Unmarshal(line, &m); err != nil {
+			Log.WithError(err).WithField("plugin", p.name).Error("Plugin sent a malformed message")
+			continue
+		}
+
+		if m.ID != nil && m.Method == "" {
+			p.lock.Lock()
+			resp, ok := p.pending[*m.ID]
+			p.lock.Unlock()
+			if ok {
+				select {
+				case resp <- &m:
+				default:
+				}
+			}
+			continue
+		}
+
+		switch m.Method {
+		case "trigger":
+			var t pluginTrigger
+			if err := json.Unmarshal(m.Params, &t); err != nil {
+				Log.WithError(err).WithField("plugin", p.name).Error("Plugin sent a bad trigger")
+				continue
+			}
+			if !strings.Contains(t.Name, "::") {
+				t.Name = p.name + "::" + t.Name
+			}
+			RunTrigger(t.Name, t.Params)
+		default:
+			Log.WithFields(logrus.Fields{"plugin": p.name, "method": m.Method}).Warn("Plugin called an unknown method")
+		}
+	}
+
+	p.lock.Lock()
+	for id, resp := range p.pending {
+		select {
+		case resp <- nil:
+		default:
+		}
+		delete(p.pending, id)
+	}
+	p.lock.Unlock()
+}
+
+// runOnce starts the plugin, initializes it and waits for it to exit. ready
+// is called once initialize has been answered, or failed.
+func (p *plugin) runOnce(ctx context.Context, ready func(*pluginManifest, error)) error {
+	cmd := exec.CommandContext(ctx, p.config.Command, p.config.Args...)
+	cmd.Dir = p.config.Dir
+	cmd.Env = os.Environ()
+	for k, v := range p.config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	// Logged line by line, so it's redacted like the rest of the log
+	stderr := Log.WithField("plugin", p.name).WriterLevel(logrus.InfoLevel)
+	defer stderr.Close()
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		ready(nil, err)
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		ready(nil, err)
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		ready(nil, err)
+		return err
+	}
+
+	p.lock.Lock()
+	p.stdin = stdin
+	p.lock.Unlock()
+
+	exited := make(chan error, 1)
+	go func() {
+		p.read(stdout)
+		exited <- cmd.Wait()
+	}()
+
+	var manifest pluginManifest
+	err = p.call(ctx, "initialize", map[string]interface{}{
+		"name":   p.name,
+		"config": currentConfig().ModuleConfig[p.name],
+	}, &manifest)
+	if err != nil {
+		err = fmt.Errorf("initialize: %s", err)
+		ready(nil, err)
+		stdin.Close()
+		cmd.Process.Kill()
+	} else {
+		ready(&manifest, nil)
+	}
+
+	exitErr := <-exited
+
+	p.lock.Lock()
+	p.stdin = nil
+	p.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return exitErr
+}
+
+// supervise keeps the plugin running until ctx is cancelled, restarting it
+// with an increasing delay when it exits.
+func (p *plugin) supervise(ctx context.Context, ready func(*pluginManifest, error)) {
+	defer close(p.done)
+
+	delay := time.Second
+	for {
+		started := time.Now()
+		err := p.runOnce(ctx, ready)
+
+		p.lock.Lock()
+		stopping := p.stopping
+		p.lock.Unlock()
+		if ctx.Err() != nil || stopping {
+			return
+		}
+
+		// Only the first start is waited for. A plugin that failed it
+		// registers its actions once it does start.
+		ready = func(m *pluginManifest, err error) {
+			if err != nil || p.isRegistered() {
+				return
+			}
+			if err := p.register(m); err != nil {
+				Log.WithError(err).WithField("plugin", p.name).Error("Failed to register plugin")
+				return
+			}
+			Log.WithField("plugin", p.name).Info("Plugin started")
+		}
+
+		if time.Since(started) > time.Minute {
+			delay = time.Second
+		}
+		Log.WithError(err).WithFields(logrus.Fields{"plugin": p.name, "delay": delay.String()}).Warn("Plugin exited, restarting")
+		if Sleep(ctx, delay) != nil {
+			return
+		}
+		if delay < time.Minute {
+			delay *= 2
+		}
+	}
+}
+
+func (p *plugin) isRegistered() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.registered
+}
+
+func (p *plugin) register(manifest *pluginManifest) error {
+	m := Module{
+		Name:        p.name,
+		Actions:     make(map[string]ActionFunc),
+		ActionSpecs: make(map[string]ActionSpec),
+		Reconfigure: func(c json.RawMessage) error {
+			return p.call(context.Background(), "configure", map[string]json.RawMessage{"config": c}, nil)
+		},
+		// supervise restarts it
+		Health: func() error {
+			p.lock.Lock()
+			defer p.lock.Unlock()
+
+			if p.stdin == nil {
+				return fmt.Errorf("Plugin %s is not running", p.name)
+			}
+			return nil
+		},
+	}
+
+	for _, a := range manifest.Actions {
+		m.Actions[a.Name] = func(ctx context.Context, a Action, cmd Params) error {
+			var result pluginActionResult
+			err := p.call(ctx, "action", map[string]interface{}{
+				"action": a,
+				"params": cmd,
+			}, &result)
+			if err != nil || result.Say == "" {
+				return err
+			}
+			return Reply(ctx, cmd, result.Say)
+		}
+
+		var spec ActionSpec
+		for _, arg := range a.Args {
+			spec.Args = append(spec.Args, ArgSpec{
+				Name:     arg.Name,
+				Type:     parseArgType(arg.Type),
+				Required: arg.Required,
+				Enum:     arg.Enum,
+			})
+		}
+		m.ActionSpecs[a.Name] = spec
+	}
+
+	for _, t := range manifest.Triggers {
+		if !strings.Contains(t, "::") {
+			t = p.name + "::" + t
+		}
+		m.Triggers = append(m.Triggers, t)
+	}
+
+	if getModule(p.name) != nil {
+		return fmt.Errorf("A module named %s exists already", p.name)
+	}
+	if err := RegisterModule(m); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	p.registered = true
+	p.lock.Unlock()
+	return nil
+}
+
+// StartPlugins starts the configured plugins and registers their actions.
+// Plugins that fail to start are logged and retried in the background, and
+// register their actions once they start.
+func StartPlugins() {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopPlugins = cancel
+
+	for name, pc := range currentConfig().Plugins {
+		p := &plugin{
+			name:    name,
+			config:  pc,
+			pending: make(map[uint64]chan *rpcMessage),
+			done:    make(chan struct{}),
+		}
+
+		result := make(chan error, 1)
+		go p.supervise(ctx, func(m *pluginManifest, err error) {
+			if err == nil {
+				err = p.register(m)
+			}
+			result <- err
+		})
+
+		if err := <-result; err != nil {
+			Log.WithError(err).WithField("plugin", name).Error("Plugin failed to start")
+		} else {
+			Log.WithField("plugin", name).Info("Plugin started")
+		}
+
+		pluginsLock.Lock()
+		plugins = append(plugins, p)
+		pluginsLock.Unlock()
+	}
+}
+
+// StopPlugins closes the plugins' stdin so they can exit cleanly, and kills
+// those still running after the timeout.
+func StopPlugins(timeout time.Duration) {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+
+	for _, p := range plugins {
+		p.lock.Lock()
+		p.stopping = true
+		if p.stdin != nil {
+			p.stdin.Close()
+		}
+		p.lock.Unlock()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for _, p := range plugins {
+			<-p.done
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+	stopPlugins()
+}
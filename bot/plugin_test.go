@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPluginResponseWhileWriteBlocks(t *testing.T) {
+	// Nothing reads the plugin's stdin, so writes block
+	r, w := io.Pipe()
+	defer r.Close()
+
+	p := &plugin{name: "stuck", stdin: w, pending: make(map[uint64]chan *rpcMessage)}
+	resp := make(chan *rpcMessage, 1)
+	p.pending[1] = resp
+
+	go p.write(rpcMessage{Method: "cancel"})
+	time.Sleep(50 * time.Millisecond)
+
+	go p.read(strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "result": {}}` + "\n"))
+
+	select {
+	case m := <-resp:
+		if m == nil || *m.ID != 1 {
+			t.Errorf("got %+v, want the response to call 1", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the response was held up by the blocked write")
+	}
+}
+
+// A plugin that exits the first time it's started, then answers initialize
+// with a Roll action and waits for stdin to close.
+const flakyPlugin = `#!/bin/sh
+if [ ! -e started ]; then
+	touch started
+	exit 1
+fi
+read -r line
+id=$(echo "$line" | sed 's/.*"id":\([0-9]*\).*/\1/')
+echo '{"jsonrpc": "2.0", "id": '"$id"', "result": {"actions": [{"name": "Roll"}]}}'
+cat > /dev/null
+`
+
+func TestPluginRegistersAfterFailedStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "flaky.sh")
+	if err := ioutil.WriteFile(script, []byte(flakyPlugin), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	useTestConfig(t, `{"plugins": {"flaky": {"command": "`+script+`", "dir": "`+dir+`"}}}`)
+	forgetModule(t, "flaky")
+	StartPlugins()
+	defer StopPlugins(time.Second)
+
+	if _, ok := lookupAction("flaky::Roll"); ok {
+		t.Fatal("registered before the plugin started")
+	}
+
+	// Restarted after a second
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := lookupAction("flaky::Roll"); ok {
+			return
+		}
+		if Sleep(context.Background(), 100*time.Millisecond) != nil {
+			break
+		}
+	}
+	t.Fatal("flaky::Roll wasn't registered after the plugin restarted")
+}
@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrQueueFull = errors.New("queue is full")
+
+const defaultQueueDepth = 10
+
+type QueueConfig struct {
+	MaxDepth            int  `json:"maxDepth"`
+	BroadcasterPriority bool `json:"broadcasterPriority"`
+}
+
+type queueJob struct {
+	ctx      context.Context
+	priority bool
+	timeout  time.Duration
+	run      func(context.Context) error
+	done     chan error
+}
+
+// actionQueue runs jobs one at a time in the order they were queued. Priority
+// jobs skip ahead of everything that isn't a priority job.
+type actionQueue struct {
+	lock    sync.Mutex
+	jobs    []*queueJob
+	running bool
+}
+
+var queueLock sync.Mutex
+var queues = make(map[string]*actionQueue)
+
+func getQueue(name string) *actionQueue {
+	queueLock.Lock()
+	defer queueLock.Unlock()
+
+	q, ok := queues[name]
+	if !ok {
+		q = &actionQueue{}
+		queues[name] = q
+	}
+	return q
+}
+
+func queueConfig(name string) QueueConfig {
+	qc := currentConfig().Queues[name]
+	if qc.MaxDepth <= 0 {
+		qc.MaxDepth = defaultQueueDepth
+	}
+	return qc
+}
+
+// runQueued waits for every job ahead of it on the named queue to finish, then
+// runs f with timeout and returns its result. The timeout starts once f does,
+// time spent waiting doesn't count. Queue names are free form, e.g. "hue" for
+// a whole module or "hue:Office" for a single room.
+func runQueued(ctx context.Context, name string, cmd Params, timeout time.Duration, f func(context.Context) error) error {
+	qc := queueConfig(name)
+	job := &queueJob{
+		ctx:      ctx,
+		priority: qc.BroadcasterPriority && cmd.UserHasBadge("broadcaster"),
+		timeout:  timeout,
+		run:      f,
+		done:     make(chan error, 1),
+	}
+
+	if err := getQueue(name).push(job, qc.MaxDepth); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		// The worker skips jobs whose context is done
+		return ctx.Err()
+	}
+}
+
+func (q *actionQueue) push(job *queueJob, maxDepth int) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.jobs) >= maxDepth {
+		return ErrQueueFull
+	}
+
+	if job.priority {
+		i := 0
+		for i < len(q.jobs) && q.jobs[i].priority {
+			i++
+		}
+		q.jobs = append(q.jobs, nil)
+		copy(q.jobs[i+1:], q.jobs[i:])
+		q.jobs[i] = job
+	} else {
+		q.jobs = append(q.jobs, job)
+	}
+
+	if !q.running {
+		q.running = true
+		go q.work()
+	}
+	return nil
+}
+
+func (q *actionQueue) work() {
+	for {
+		q.lock.Lock()
+		if len(q.jobs) == 0 {
+			q.running = false
+			q.lock.Unlock()
+			return
+		}
+		job := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		q.lock.Unlock()
+
+		if err := job.ctx.Err(); err != nil {
+			job.done <- err
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(job.ctx, job.timeout)
+		job.done <- job.run(ctx)
+		cancel()
+	}
+}
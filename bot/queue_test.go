@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queueTestJob runs on the queue, recording name once it has run for d.
+func queueTestJob(name string, d time.Duration) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if err := Sleep(ctx, d); err != nil {
+			return err
+		}
+		ranActions.add(name)
+		return nil
+	}
+}
+
+func TestRunQueuedOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		queue QueueConfig
+		want  []string
+	}{
+		{name: "in order", want: []string{"first", "viewer", "broadcaster", "viewer 2"}},
+		{name: "broadcaster priority", queue: QueueConfig{BroadcasterPriority: true}, want: []string{"first", "broadcaster", "viewer", "viewer 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := useTestConfig(t, `{}`)
+			c.Queues = map[string]QueueConfig{"order": tt.queue}
+
+			viewer := Params{UserID: "1"}
+			broadcaster := Params{UserID: "2", UserBadges: map[string]int{"broadcaster": 1}}
+
+			var wg sync.WaitGroup
+			run := func(cmd Params, name string, d time.Duration) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := runQueued(context.Background(), "order", cmd, time.Second, queueTestJob(name, d)); err != nil {
+						t.Error(err)
+					}
+				}()
+				// Queue them in a known order
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			// Holds the queue while the rest line up
+			run(viewer, "first", 100*time.Millisecond)
+			run(viewer, "viewer", 0)
+			run(broadcaster, "broadcaster", 0)
+			run(viewer, "viewer 2", 0)
+			wg.Wait()
+
+			if got := ranActions.take(); !sameItems(got, tt.want) {
+				t.Errorf("ran %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunQueuedFull(t *testing.T) {
+	c := useTestConfig(t, `{}`)
+	c.Queues = map[string]QueueConfig{"full": {MaxDepth: 1}}
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- runQueued(context.Background(), "full", Params{}, time.Second, queueTestJob("job", 100*time.Millisecond))
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The first job is running, the second waits, the third doesn't fit
+	if err := runQueued(context.Background(), "full", Params{}, time.Second, queueTestJob("job", 0)); err != ErrQueueFull {
+		t.Errorf("got %v, want ErrQueueFull", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRunQueuedTimeout(t *testing.T) {
+	useTestConfig(t, `{}`)
+
+	// Runs for longer than the next job's timeout
+	go runQueued(context.Background(), "timeout", Params{}, time.Second, queueTestJob("slow", 150*time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	// Only counts the time it runs
+	if err := runQueued(context.Background(), "timeout", Params{}, 100*time.Millisecond, queueTestJob("waited", 50*time.Millisecond)); err != nil {
+		t.Errorf("waiting counted against the timeout: %v", err)
+	}
+	if err := runQueued(context.Background(), "timeout", Params{}, 50*time.Millisecond, queueTestJob("too slow", 100*time.Millisecond)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the job to time out", err)
+	}
+
+	want := []string{"slow", "waited"}
+	if got := ranActions.take(); !sameItems(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+func TestRunQueuedCancelledWhileWaiting(t *testing.T) {
+	useTestConfig(t, `{}`)
+
+	go runQueued(context.Background(), "cancel", Params{}, time.Second, queueTestJob("first", 100*time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := runQueued(ctx, "cancel", Params{}, time.Second, queueTestJob("cancelled", 0)); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// Wait for the queue to drain, the cancelled job must be skipped
+	if err := runQueued(context.Background(), "cancel", Params{}, time.Second, queueTestJob("last", 0)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"first", "last"}
+	if got := ranActions.take(); !sameItems(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+func TestQueuedCommandTimeout(t *testing.T) {
+	useTestConfig(t, `{"commands": {
+		"slow": {"enabled": true, "offline": true, "queue": "lights", "actions": [{"name": "test::Sleep", "args": {"name": "slow", "duration": "150ms"}}]},
+		"quick": {"enabled": true, "offline": true, "queue": "lights", "timeout": "100ms", "actions": [{"name": "test::Sleep", "args": {"name": "quick", "duration": "50ms"}}]}
+	}}`)
+
+	go ExecuteCommand(context.Background(), Params{UserID: "1", Command: "slow"})
+	time.Sleep(10 * time.Millisecond)
+
+	if err := ExecuteCommand(context.Background(), Params{UserID: "1", Command: "quick"}); err != nil {
+		t.Errorf("waiting counted against the timeout: %v", err)
+	}
+	want := []string{"slow", "quick"}
+	if got := ranActions.take(); !sameItems(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,249 @@
+package bot
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nicklaw5/helix"
+	"go.etcd.io/bbolt"
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// Quote is something memorable said on stream. Author is who added it and
+// Category is the game or category being streamed at the time.
+type Quote struct {
+	ID       uint64    `json:"id"`
+	Text     string    `json:"text"`
+	Author   string    `json:"author"`
+	Date     time.Time `json:"date"`
+	Category string    `json:"category"`
+}
+
+func (q Quote) String() string {
+	s := fmt.Sprintf("Quote #%d: %s", q.ID, q.Text)
+	if q.Category != "" {
+		s += " [" + q.Category + "]"
+	}
+	return s + " " + q.Date.Format("2006-01-02")
+}
+
+func quoteKey(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
+
+// AddQuote stores q under the next free id, which is returned.
+func AddQuote(q Quote) (uint64, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(QUOTE_BUCKET)
+
+		var err error
+		if q.ID, err = b.NextSequence(); err != nil {
+			return err
+		}
+
+		j, err := json.Marshal(q)
+		if err != nil {
+			return err
+		}
+		return b.Put(quoteKey(q.ID), j)
+	})
+
+	return q.ID, err
+}
+
+func GetQuote(id uint64) (*Quote, error) {
+	var q *Quote
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(QUOTE_BUCKET).Get(quoteKey(id))
+		if v == nil {
+			return nil
+		}
+
+		q = &Quote{}
+		return json.Unmarshal(v, q)
+	})
+
+	return q, err
+}
+
+// ListQuotes returns every quote ordered by id.
+func ListQuotes() ([]Quote, error) {
+	quotes := make([]Quote, 0)
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(QUOTE_BUCKET).ForEach(func(k, v []byte) error {
+			var q Quote
+			if err := json.Unmarshal(v, &q); err != nil {
+				return err
+			}
+			quotes = append(quotes, q)
+			return nil
+		})
+	})
+
+	return quotes, err
+}
+
+// SearchQuotes returns the quotes containing term, ignoring case.
+func SearchQuotes(term string) ([]Quote, error) {
+	quotes, err := ListQuotes()
+	if err != nil {
+		return nil, err
+	}
+
+	term = strings.ToLower(term)
+	matches := make([]Quote, 0)
+	for _, q := range quotes {
+		if strings.Contains(strings.ToLower(q.Text), term) {
+			matches = append(matches, q)
+		}
+	}
+	return matches, nil
+}
+
+// ImportQuotes stores quotes keeping their ids, replacing any existing quote
+// with the same id. Quotes without an id are given the next free one, and
+// those without a date are dated now.
+func ImportQuotes(quotes []Quote) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(QUOTE_BUCKET)
+
+		for _, q := range quotes {
+			if q.Date.IsZero() {
+				q.Date = time.Now()
+			}
+
+			if q.ID == 0 {
+				var err error
+				if q.ID, err = b.NextSequence(); err != nil {
+					return err
+				}
+			} else if q.ID > b.Sequence() {
+				if err := b.SetSequence(q.ID); err != nil {
+					return err
+				}
+			}
+
+			j, err := json.Marshal(q)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(quoteKey(q.ID), j); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// streamCategory returns the name of the category the main channel is
+// streaming in, or an empty string when offline.
+func streamCategory() string {
+	client := GetHelixClient()
+	if client == nil {
+		return ""
+	}
+
+	streams, err := client.GetStreams(&helix.StreamsParams{
+		UserLogins: []string{getMainChannel()},
+	})
+	if err != nil || len(streams.Data.Streams) == 0 {
+		return ""
+	}
+
+	games, err := client.GetGames(&helix.GamesParams{
+		IDs: []string{streams.Data.Streams[0].GameID},
+	})
+	if err != nil || len(games.Data.Games) == 0 {
+		return ""
+	}
+	return games.Data.Games[0].Name
+}
+
+// quoteCmd handles the quote commands:
+//
+//	!quote                   a random quote
+//	!quote 42                quote number 42
+//	!quote add <text>        add a quote
+//	!quote search <term>     find quotes containing term
+func quoteCmd(ctx context.Context, cmd Params) error {
+	args := cmd.CommandArgs
+
+	switch {
+	case len(args) == 0:
+		quotes, err := ListQuotes()
+		if err != nil {
+			return err
+		}
+		if len(quotes) == 0 {
+			return Reply(ctx, cmd, "There are no quotes yet, add one with !quote add <text>")
+		}
+		return Reply(ctx, cmd, quotes[rand.Intn(len(quotes))].String())
+
+	case args[0] == "add":
+		if len(args) < 2 {
+			return Reply(ctx, cmd, "usage: !quote add <text>")
+		}
+
+		id, err := AddQuote(Quote{
+			Text:     strings.Join(args[1:], " "),
+			Author:   cmd.UserName,
+			Date:     time.Now(),
+			Category: streamCategory(),
+		})
+		if err != nil {
+			return err
+		}
+		return Reply(ctx, cmd, fmt.Sprintf("Added quote #%d", id))
+
+	case args[0] == "search":
+		if len(args) < 2 {
+			return Reply(ctx, cmd, "usage: !quote search <term>")
+		}
+
+		term := strings.Join(args[1:], " ")
+		matches, err := SearchQuotes(term)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return Reply(ctx, cmd, fmt.Sprintf("No quotes found for '%s'", term))
+		}
+
+		msg := matches[0].String()
+		if len(matches) > 1 {
+			ids := make([]string, 0, len(matches)-1)
+			for _, q := range matches[1:] {
+				ids = append(ids, "#"+strconv.FormatUint(q.ID, 10))
+			}
+			msg += " (also " + strings.Join(ids, ", ") + ")"
+		}
+		return Reply(ctx, cmd, msg)
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		return Reply(ctx, cmd, "usage: !quote, !quote <number>, !quote add <text>, !quote search <term>")
+	}
+
+	q, err := GetQuote(id)
+	if err != nil {
+		return err
+	}
+	if q == nil {
+		return Reply(ctx, cmd, fmt.Sprintf("Quote #%d does not exist", id))
+	}
+	return Reply(ctx, cmd, q.String())
+}
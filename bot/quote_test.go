@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportQuotes(t *testing.T) {
+	openTestDatabase(t)
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	err := ImportQuotes([]Quote{
+		{ID: 5, Text: "five", Date: date},
+		{ID: 2, Text: "two", Date: date},
+		{Text: "no id", Date: date},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// New quotes never reuse an imported id
+	id, err := AddQuote(Quote{Text: "added", Date: date})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 {
+		t.Errorf("added quote #%d, want #7", id)
+	}
+
+	quotes, err := ListQuotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, q := range quotes {
+		got = append(got, q.String())
+	}
+	want := []string{
+		"Quote #2: two 2024-03-01",
+		"Quote #5: five 2024-03-01",
+		"Quote #6: no id 2024-03-01",
+		"Quote #7: added 2024-03-01",
+	}
+	if !sameItems(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestQuoteCommand(t *testing.T) {
+	useTestConfig(t, `{}`)
+	openTestDatabase(t)
+
+	today := time.Now().Format("2006-01-02")
+	// Run in order against the same database
+	steps := []struct {
+		command string
+		reply   string
+	}{
+		{command: "quote", reply: "There are no quotes yet, add one with !quote add <text>"},
+		{command: "quote add", reply: "usage: !quote add <text>"},
+		{command: "quote add It works on my machine", reply: "Added quote #1"},
+		{command: "quote add Ship it", reply: "Added quote #2"},
+		{command: "quote add It's fine", reply: "Added quote #3"},
+		{command: "quote 2", reply: "Quote #2: Ship it " + today},
+		{command: "quote #1", reply: "Quote #1: It works on my machine " + today},
+		{command: "quote 9", reply: "Quote #9 does not exist"},
+		{command: "quote search IT", reply: "Quote #1: It works on my machine " + today + " (also #2, #3)"},
+		{command: "quote search machine", reply: "Quote #1: It works on my machine " + today},
+		{command: "quote search nothing", reply: "No quotes found for 'nothing'"},
+		{command: "quote hello", reply: "usage: !quote, !quote <number>, !quote add <text>, !quote search <term>"},
+	}
+
+	for _, s := range steps {
+		args := strings.Fields(s.command)
+		err := ExecuteCommand(context.Background(), Params{Provider: "test", UserID: "1", UserName: "erik", Command: args[0], CommandArgs: args[1:]})
+		if err != nil {
+			t.Fatalf("%s: %s", s.command, err)
+		}
+
+		want := []string{s.reply}
+		if got := replies.take(); !sameItems(got, want) {
+			t.Errorf("%s: replied %q, want %q", s.command, got, want)
+		}
+	}
+}
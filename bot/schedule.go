@@ -0,0 +1,222 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Schedule runs a trigger, a list of actions, or both at the times given by a
+// cron expression such as "0 22 * * *", in Timezone or the config's timezone.
+type Schedule struct {
+	Cron     string   `json:"cron"`
+	Timezone string   `json:"timezone"`
+	Trigger  string   `json:"trigger"`
+	Actions  []Action `json:"actions"`
+
+	schedule cron.Schedule
+}
+
+// ScheduledRun lists the upcoming fire times of a schedule or cron trigger.
+type ScheduledRun struct {
+	Name  string
+	Cron  string
+	Times []time.Time
+}
+
+func parseCron(expr string, timezone string) (cron.Schedule, error) {
+	loc := time.Local
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := cronParser.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expressions may set their own zone with CRON_TZ=, otherwise use ours
+	if spec, ok := s.(*cron.SpecSchedule); ok && spec.Location == time.Local {
+		spec.Location = loc
+	}
+	return s, nil
+}
+
+func (c *Config) compileSchedules() error {
+	for name, s := range c.Schedules {
+		if s == nil || s.Cron == "" {
+			return fmt.Errorf("Schedule '%s' needs a cron expression", name)
+		}
+		if s.Trigger == "" && len(s.Actions) == 0 {
+			return fmt.Errorf("Schedule '%s' needs a trigger or actions", name)
+		}
+
+		tz := s.Timezone
+		if tz == "" {
+			tz = c.Timezone
+		}
+
+		var err error
+		if s.schedule, err = parseCron(s.Cron, tz); err != nil {
+			return fmt.Errorf("Schedule '%s': %s", name, err)
+		}
+	}
+
+	for name, t := range c.Triggers {
+		if t.Cron == "" {
+			continue
+		}
+
+		tz := t.Timezone
+		if tz == "" {
+			tz = c.Timezone
+		}
+
+		var err error
+		if t.schedule, err = parseCron(t.Cron, tz); err != nil {
+			return fmt.Errorf("Trigger '%s': %s", name, err)
+		}
+		c.Triggers[name] = t
+	}
+
+	return nil
+}
+
+type scheduleEntry struct {
+	name     string
+	cron     string
+	schedule cron.Schedule
+	next     time.Time
+	run      func(ctx context.Context, cmd Params) error
+}
+
+func (c *Config) scheduleEntries() []*scheduleEntry {
+	entries := make([]*scheduleEntry, 0)
+
+	for name, s := range c.Schedules {
+		s := s
+		entries = append(entries, &scheduleEntry{
+			name:     name,
+			cron:     s.Cron,
+			schedule: s.schedule,
+			run: func(ctx context.Context, cmd Params) error {
+				var err error
+				if s.Trigger != "" {
+					err = ExecuteTrigger(ctx, s.Trigger, cmd)
+				}
+				if actionsErr := runTriggerActions(ctx, Trigger{Actions: s.Actions}, cmd); err == nil {
+					err = actionsErr
+				}
+				return err
+			},
+		})
+	}
+
+	for name, t := range c.Triggers {
+		if t.schedule == nil {
+			continue
+		}
+
+		name := name
+		entries = append(entries, &scheduleEntry{
+			name:     name,
+			cron:     t.Cron,
+			schedule: t.schedule,
+			run: func(ctx context.Context, cmd Params) error {
+				return ExecuteTrigger(ctx, name, cmd)
+			},
+		})
+	}
+
+	return entries
+}
+
+// RunSchedules fires schedules and cron triggers until ctx is cancelled.
+// Schedules are rebuilt whenever the config is reloaded.
+func RunSchedules(ctx context.Context) {
+	var c *Config
+	var entries []*scheduleEntry
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if current := currentConfig(); current != c {
+				c = current
+				entries = c.scheduleEntries()
+				for _, e := range entries {
+					e.next = e.schedule.Next(now)
+				}
+			}
+
+			for _, e := range entries {
+				if now.Before(e.next) {
+					continue
+				}
+				fireSchedule(e, e.next)
+				e.next = e.schedule.Next(now)
+			}
+		}
+	}
+}
+
+func fireSchedule(e *scheduleEntry, at time.Time) {
+	cmd := Params{
+		Channel: getMainChannel(),
+		Command: e.name,
+		Payload: map[string]string{
+			"scheduledAt": at.Format(time.RFC3339),
+		},
+	}
+
+	runner.run(func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout(0))
+		defer cancel()
+
+		if err := e.run(ctx, cmd); err != nil {
+			Log.WithError(err).WithField("schedule", e.name).Error("Schedule failed")
+		}
+	})
+}
+
+// ListSchedules returns the next n fire times of every schedule and cron
+// trigger in the current config, sorted by the first fire time.
+func ListSchedules(n int) []ScheduledRun {
+	c := currentConfig()
+	now := time.Now()
+
+	runs := make([]ScheduledRun, 0)
+	for _, e := range c.scheduleEntries() {
+		run := ScheduledRun{Name: e.name, Cron: e.cron}
+
+		next := now
+		for i := 0; i < n; i++ {
+			next = e.schedule.Next(next)
+			if next.IsZero() {
+				break
+			}
+			run.Times = append(run.Times, next)
+		}
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		if len(runs[i].Times) == 0 || len(runs[j].Times) == 0 {
+			return len(runs[i].Times) > len(runs[j].Times)
+		}
+		return runs[i].Times[0].Before(runs[j].Times[0])
+	})
+	return runs
+}
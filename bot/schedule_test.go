@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr     string
+		timezone string
+		want     time.Time
+		err      bool
+	}{
+		{expr: "0 22 * * *", timezone: "UTC", want: time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)},
+		{expr: "0 22 * * *", timezone: "Europe/London", want: time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)},
+		{expr: "0 22 * * *", timezone: "America/New_York", want: time.Date(2024, 3, 2, 3, 0, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=America/New_York 0 22 * * *", timezone: "UTC", want: time.Date(2024, 3, 2, 3, 0, 0, 0, time.UTC)},
+		{expr: "@hourly", timezone: "UTC", want: time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{expr: "0 9 * * MON", timezone: "UTC", want: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{expr: "0 22 * *", timezone: "UTC", err: true},
+		{expr: "0 0 22 * * *", timezone: "UTC", err: true},
+		{expr: "0 22 * * *", timezone: "Nowhere/Special", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr+" "+tt.timezone, func(t *testing.T) {
+			s, err := parseCron(tt.expr, tt.timezone)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileSchedulesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "no cron", config: `{"schedules": {"nightly": {"trigger": "bot::Startup"}}}`},
+		{name: "nothing to run", config: `{"schedules": {"nightly": {"cron": "0 22 * * *"}}}`},
+		{name: "bad cron", config: `{"schedules": {"nightly": {"cron": "22 * *", "trigger": "bot::Startup"}}}`},
+		{name: "bad timezone", config: `{"timezone": "Nowhere/Special", "schedules": {"nightly": {"cron": "0 22 * * *", "trigger": "bot::Startup"}}}`},
+		{name: "bad trigger cron", config: `{"triggers": {"nightly": {"cron": "22 * *"}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseConfig(strings.NewReader(tt.config)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestScheduleEntries(t *testing.T) {
+	c := useTestConfig(t, `{
+		"schedules": {
+			"nightly": {"cron": "0 22 * * *", "trigger": "reminder", "actions": [{"name": "test::Record", "args": {"name": "nightly {{payload.scheduledAt}}"}}]}
+		},
+		"triggers": {
+			"reminder": {"actions": [{"name": "test::Record", "args": {"name": "reminder"}}]},
+			"hourly": {"cron": "@hourly", "actions": [{"name": "test::Record", "args": {"name": "hourly"}}]}
+		}
+	}`)
+
+	entries := make(map[string]*scheduleEntry)
+	for _, e := range c.scheduleEntries() {
+		entries[e.name] = e
+	}
+	if len(entries) != 2 || entries["nightly"] == nil || entries["hourly"] == nil {
+		t.Fatalf("got entries %v, want nightly and hourly", entries)
+	}
+
+	at := time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)
+	fireSchedule(entries["nightly"], at)
+	WaitForCommands(time.Second)
+	want := []string{"reminder", "nightly 2024-03-01T22:00:00Z"}
+	if got := ranActions.take(); !sameItems(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+
+	if err := entries["hourly"].run(context.Background(), Params{}); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"hourly"}
+	if got := ranActions.take(); !sameItems(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+func TestListSchedules(t *testing.T) {
+	useTestConfig(t, `{
+		"timezone": "UTC",
+		"schedules": {
+			"yearly": {"cron": "@yearly", "trigger": "bot::Startup"},
+			"often": {"cron": "* * * * *", "trigger": "bot::Startup"}
+		}
+	}`)
+
+	runs := ListSchedules(3)
+	if len(runs) != 2 {
+		t.Fatalf("got %d schedules, want 2", len(runs))
+	}
+	if runs[0].Name != "often" || runs[1].Name != "yearly" {
+		t.Errorf("got %s then %s, want often then yearly", runs[0].Name, runs[1].Name)
+	}
+	if len(runs[0].Times) != 3 || runs[0].Times[1].Sub(runs[0].Times[0]) != time.Minute {
+		t.Errorf("got times %v, want three a minute apart", runs[0].Times)
+	}
+}
@@ -0,0 +1,273 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	ArgInt
+	ArgBool
+	ArgDuration
+	ArgEnum
+)
+
+func (t ArgType) String() string {
+	switch t {
+	case ArgInt:
+		return "int"
+	case ArgBool:
+		return "bool"
+	case ArgDuration:
+		return "duration"
+	case ArgEnum:
+		return "enum"
+	}
+	return "string"
+}
+
+// ArgSpec describes a single action argument.
+type ArgSpec struct {
+	Name     string
+	Type     ArgType
+	Required bool
+	Enum     []string
+
+	// Validate is an optional extra check, e.g. that a color name exists.
+	Validate func(string) error
+}
+
+// ActionSpec lists the arguments an action accepts. Actions without a spec
+// are only checked for existence.
+type ActionSpec struct {
+	Args []ArgSpec
+}
+
+// Triggers fired by the bot itself rather than a module.
+var builtinTriggers = []string{
+	"bot::Startup",
+	"bot::Shutdown",
+	"bot::StreamStarted",
+	"bot::StreamStopped",
+}
+
+func (s ArgSpec) check(value string) error {
+	// Templated values can only be checked once rendered
+	if strings.Contains(value, "{{") {
+		return nil
+	}
+
+	switch s.Type {
+	case ArgInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("'%s' is not an integer", value)
+		}
+	case ArgBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("'%s' is not true or false", value)
+		}
+	case ArgDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("'%s' is not a duration", value)
+		}
+	case ArgEnum:
+		found := false
+		for _, e := range s.Enum {
+			if e == value {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("'%s' must be one of %s", value, strings.Join(s.Enum, ", "))
+		}
+	}
+
+	if s.Validate != nil {
+		return s.Validate(value)
+	}
+	return nil
+}
+
+func getActionSpec(name string) (ActionSpec, bool) {
+	parts := strings.SplitN(name, "::", 2)
+	if len(parts) != 2 {
+		return ActionSpec{}, false
+	}
+
+	m := getModule(parts[0])
+	if m == nil {
+		return ActionSpec{}, false
+	}
+	spec, ok := m.ActionSpecs[parts[1]]
+	return spec, ok
+}
+
+func isKnownTrigger(name string) bool {
+	known := append([]string{}, builtinTriggers...)
+	for _, m := range registeredModules() {
+		known = append(known, m.Triggers...)
+	}
+
+	for _, t := range known {
+		if t == name {
+			return true
+		}
+		// "obs::SceneChanged::*" matches any scene
+		if strings.HasSuffix(t, "*") && strings.HasPrefix(name, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// unstartedPlugin reports whether module is a plugin that hasn't started yet.
+// Plugins only tell us their actions and triggers once they are running.
+func (c *Config) unstartedPlugin(module string) bool {
+	_, ok := c.Plugins[module]
+	return ok && getModule(module) == nil
+}
+
+func validateAction(c *Config, where string, a Action) []error {
+	var errs []error
+
+	for i, ea := range a.ErrorActions {
+		errs = append(errs, validateAction(c, fmt.Sprintf("%s error action %d", where, i+1), ea)...)
+	}
+
+	module := strings.SplitN(a.Name, "::", 2)[0]
+
+	if c.unstartedPlugin(module) {
+		return nil
+	}
+
+	if _, ok := lookupAction(a.Name); !ok {
+		return append(errs, fmt.Errorf("%s: unknown action '%s'", where, a.Name))
+	}
+
+	if !c.moduleEnabled(module) {
+		errs = append(errs, fmt.Errorf("%s: action '%s' belongs to module '%s' which is not enabled", where, a.Name, module))
+	}
+
+	spec, ok := getActionSpec(a.Name)
+	if !ok {
+		return errs
+	}
+
+	provided := make(map[string]bool)
+	for name := range a.Args {
+		provided[name] = true
+	}
+	for _, name := range a.UserArgMap {
+		provided[name] = true
+	}
+
+	known := make(map[string]bool)
+	for _, arg := range spec.Args {
+		known[arg.Name] = true
+
+		if arg.Required && !provided[arg.Name] {
+			errs = append(errs, fmt.Errorf("%s: action '%s' is missing required argument '%s'", where, a.Name, arg.Name))
+			continue
+		}
+
+		if v, ok := a.Args[arg.Name]; ok {
+			if err := arg.check(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: action '%s' argument '%s': %s", where, a.Name, arg.Name, err))
+			}
+		}
+	}
+
+	for name := range provided {
+		if !known[name] {
+			errs = append(errs, fmt.Errorf("%s: action '%s' has unknown argument '%s'", where, a.Name, name))
+		}
+	}
+
+	return errs
+}
+
+// ValidateConfig checks every command and trigger against the registered
+// modules and returns all problems found, sorted for stable output.
+func ValidateConfig(c *Config) []error {
+	var errs []error
+
+	if err := validateConfig(c); err != nil {
+		errs = append(errs, err)
+	}
+
+	for name, cmd := range c.Commands {
+		if cmd == nil {
+			continue
+		}
+		for i, a := range cmd.Actions {
+			errs = append(errs, validateAction(c, fmt.Sprintf("command '%s' action %d", name, i+1), a)...)
+		}
+	}
+
+	scheduled := make(map[string]bool)
+	for _, s := range c.Schedules {
+		if s != nil {
+			scheduled[s.Trigger] = true
+		}
+	}
+
+	for name, t := range c.Triggers {
+		module := strings.SplitN(name, "::", 2)[0]
+		if t.Cron == "" && !scheduled[name] && !isKnownTrigger(name) && !c.unstartedPlugin(module) {
+			errs = append(errs, fmt.Errorf("trigger '%s': unknown trigger name", name))
+		}
+		for i, a := range t.Actions {
+			errs = append(errs, validateAction(c, fmt.Sprintf("trigger '%s' action %d", name, i+1), a)...)
+		}
+	}
+
+	for i, t := range c.ChatTriggers {
+		where := fmt.Sprintf("chat trigger '%s'", t.Name)
+		if t.Name == "" {
+			where = fmt.Sprintf("chat trigger %d", i+1)
+		}
+
+		if t.Command != "" {
+			if _, ok := lookupCommand(c, t.Command); !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown command '%s'", where, t.Command))
+			}
+		}
+		for j, a := range t.Actions {
+			errs = append(errs, validateAction(c, fmt.Sprintf("%s action %d", where, j+1), a)...)
+		}
+	}
+
+	for name, t := range c.Timers {
+		if t == nil {
+			continue
+		}
+		for i, a := range t.Actions {
+			errs = append(errs, validateAction(c, fmt.Sprintf("timer '%s' action %d", name, i+1), a)...)
+		}
+	}
+
+	for name, s := range c.Schedules {
+		if s == nil {
+			continue
+		}
+		if s.Trigger != "" {
+			if _, ok := c.Triggers[s.Trigger]; !ok {
+				errs = append(errs, fmt.Errorf("schedule '%s': unknown trigger '%s'", name, s.Trigger))
+			}
+		}
+		for i, a := range s.Actions {
+			errs = append(errs, validateAction(c, fmt.Sprintf("schedule '%s' action %d", name, i+1), a)...)
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return errs
+}
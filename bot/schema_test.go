@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestArgSpecCheck(t *testing.T) {
+	tests := []struct {
+		spec  ArgSpec
+		value string
+		err   bool
+	}{
+		{spec: ArgSpec{Type: ArgString}, value: "anything"},
+		{spec: ArgSpec{Type: ArgInt}, value: "42"},
+		{spec: ArgSpec{Type: ArgInt}, value: "-1"},
+		{spec: ArgSpec{Type: ArgInt}, value: "4.2", err: true},
+		{spec: ArgSpec{Type: ArgBool}, value: "true"},
+		{spec: ArgSpec{Type: ArgBool}, value: "yes", err: true},
+		{spec: ArgSpec{Type: ArgDuration}, value: "1m30s"},
+		{spec: ArgSpec{Type: ArgDuration}, value: "90", err: true},
+		{spec: ArgSpec{Type: ArgEnum, Enum: []string{"on", "off"}}, value: "off"},
+		{spec: ArgSpec{Type: ArgEnum, Enum: []string{"on", "off"}}, value: "dim", err: true},
+		{spec: ArgSpec{Type: ArgInt}, value: "{{args.0}}"},
+		{spec: ArgSpec{Validate: func(string) error { return errors.New("no") }}, value: "red", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.spec.Type, tt.value), func(t *testing.T) {
+			err := tt.spec.check(tt.value)
+			if tt.err && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	RegisterModule(Module{
+		Name: "spec",
+		Actions: map[string]ActionFunc{
+			"Blink": func(ctx context.Context, a Action, cmd Params) error {
+				return nil
+			},
+		},
+		ActionSpecs: map[string]ActionSpec{
+			"Blink": {Args: []ArgSpec{
+				{Name: "times", Type: ArgInt, Required: true},
+				{Name: "color", Type: ArgEnum, Enum: []string{"red", "blue"}},
+			}},
+		},
+	})
+	forgetModule(t, "spec")
+
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name:   "valid",
+			config: `{"enabledModules": ["spec"], "commands": {"blink": {"actions": [{"name": "spec::Blink", "args": {"times": "3", "color": "red"}}]}}}`,
+		},
+		{
+			name:   "required arg from the command's args",
+			config: `{"enabledModules": ["spec"], "commands": {"blink": {"actions": [{"name": "spec::Blink", "userArgMap": ["times"]}]}}}`,
+		},
+		{
+			name:   "unknown action",
+			config: `{"enabledModules": ["spec"], "commands": {"blink": {"actions": [{"name": "spec::Flash"}]}}}`,
+			want:   []string{"command 'blink' action 1: unknown action 'spec::Flash'"},
+		},
+		{
+			name:   "module not enabled",
+			config: `{"commands": {"blink": {"actions": [{"name": "spec::Blink", "args": {"times": "3"}}]}}}`,
+			want:   []string{"command 'blink' action 1: action 'spec::Blink' belongs to module 'spec' which is not enabled"},
+		},
+		{
+			name:   "bad args",
+			config: `{"enabledModules": ["spec"], "commands": {"blink": {"actions": [{"name": "spec::Blink", "args": {"color": "green", "speed": "fast"}}]}}}`,
+			want: []string{
+				"command 'blink' action 1: action 'spec::Blink' argument 'color': 'green' must be one of red, blue",
+				"command 'blink' action 1: action 'spec::Blink' has unknown argument 'speed'",
+				"command 'blink' action 1: action 'spec::Blink' is missing required argument 'times'",
+			},
+		},
+		{
+			name:   "unknown trigger",
+			config: `{"triggers": {"spec::Nope": {}, "bot::Startup": {}}}`,
+			want:   []string{"trigger 'spec::Nope': unknown trigger name"},
+		},
+		{
+			name:   "unstarted plugin",
+			config: `{"plugins": {"dice": {"command": "dice"}}, "triggers": {"dice::Rolled": {"actions": [{"name": "dice::Roll"}]}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseConfig(strings.NewReader(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, err := range ValidateConfig(c) {
+				got = append(got, err.Error())
+			}
+			if !sameItems(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
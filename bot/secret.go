@@ -0,0 +1,289 @@
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Module config strings that are entirely a reference are replaced with what
+// they refer to before the module sees its config: $VAR and ${VAR} with the
+// environment variable and ${file:/path} with the file's contents.
+var secretRefRegexp = regexp.MustCompile(`^\$(?:([A-Za-z_][A-Za-z0-9_]*)|\{([A-Za-z_][A-Za-z0-9_]*)\}|\{file:(.+)\})$`)
+
+// Module config keys whose values are treated as secrets even when they're
+// written out in the config.
+var secretKeyRegexp = regexp.MustCompile(`(?i)password|secret|token`)
+
+const redacted = "[REDACTED]"
+
+// Secrets shorter than this aren't redacted from logs, redacting every
+// occurrence of a couple of characters would garble them.
+const minRedactedLength = 4
+
+var secretLock sync.RWMutex
+var secrets = make(map[string]bool)
+
+// Environment variables set from a .env file, which a reloaded .env file may
+// change. Variables set in the environment win over the .env file.
+var dotEnvKeys = make(map[string]bool)
+
+// LoadDotEnv sets the environment variables in a .env file of KEY=VALUE
+// lines. Blank lines and lines starting with # are skipped, and values may be
+// quoted. A missing file is not an error.
+func LoadDotEnv(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	secretLock.Lock()
+	defer secretLock.Unlock()
+
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(line, "export "), "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		if _, ok := os.LookupEnv(key); ok && !dotEnvKeys[key] {
+			continue
+		}
+		os.Setenv(key, value)
+		dotEnvKeys[key] = true
+	}
+	return s.Err()
+}
+
+// How resolveValue treats references.
+type resolveMode int
+
+const (
+	// References are kept as written
+	keepRefs resolveMode = iota
+	// References are resolved, one that can't be is an error
+	resolveRefs
+	// References are resolved where they can be and kept as written where
+	// they can't
+	resolveSetRefs
+)
+
+// resolveSecrets resolves the references in the enabled modules' configs and
+// keeps a redacted copy of every module's config for dumping the config.
+// Unless strict is set, a reference that can't be resolved is kept as
+// written rather than being an error.
+func (c *Config) resolveSecrets(strict bool) error {
+	c.redactedModuleConfig = make(map[string]json.RawMessage, len(c.ModuleConfig))
+
+	for name, raw := range c.ModuleConfig {
+		// Disabled modules never see their config, so an unset variable
+		// isn't a problem for them
+		mode := keepRefs
+		if c.moduleEnabled(name) {
+			mode = resolveSetRefs
+			if strict {
+				mode = resolveRefs
+			}
+		}
+
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("moduleConfig.%s: %s", name, err)
+		}
+
+		resolved, hidden, err := resolveValue("moduleConfig."+name, "", v, mode)
+		if err != nil {
+			return err
+		}
+
+		if c.ModuleConfig[name], err = json.Marshal(resolved); err != nil {
+			return err
+		}
+		if c.redactedModuleConfig[name], err = json.Marshal(hidden); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveValue returns v with its references resolved, as mode says, and with
+// its secrets redacted.
+func resolveValue(path string, key string, v interface{}, mode resolveMode) (interface{}, interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		hidden := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			var err error
+			if resolved[k], hidden[k], err = resolveValue(path+"."+k, k, child, mode); err != nil {
+				return nil, nil, err
+			}
+		}
+		return resolved, hidden, nil
+
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		hidden := make([]interface{}, len(v))
+		for i, child := range v {
+			var err error
+			if resolved[i], hidden[i], err = resolveValue(fmt.Sprintf("%s[%d]", path, i), key, child, mode); err != nil {
+				return nil, nil, err
+			}
+		}
+		return resolved, hidden, nil
+
+	case string:
+		if mode == keepRefs {
+			return keepRef(key, v)
+		}
+
+		s, isRef, err := resolveRef(v)
+		if err != nil {
+			if mode == resolveSetRefs {
+				return keepRef(key, v)
+			}
+			return nil, nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if !isRef && !secretKeyRegexp.MatchString(key) {
+			return v, v, nil
+		}
+
+		addSecret(s)
+		if isRef {
+			// The reference itself is safe to show and says where the value
+			// comes from
+			return s, v, nil
+		}
+		return s, redacted, nil
+	}
+
+	return v, v, nil
+}
+
+// keepRef returns s as written, and redacted if it's a secret written out
+// under a secret key.
+func keepRef(key string, s string) (interface{}, interface{}, error) {
+	if secretRefRegexp.MatchString(s) || !secretKeyRegexp.MatchString(key) {
+		return s, s, nil
+	}
+	return s, redacted, nil
+}
+
+// resolveRef resolves s if it's a reference.
+func resolveRef(s string) (string, bool, error) {
+	m := secretRefRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return s, false, nil
+	}
+
+	if m[3] != "" {
+		b, err := ioutil.ReadFile(m[3])
+		if err != nil {
+			return "", true, err
+		}
+		return strings.TrimRight(string(b), "\r\n"), true, nil
+	}
+
+	name := m[1] + m[2]
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", true, fmt.Errorf("Environment variable %s is not set", name)
+	}
+	return value, true, nil
+}
+
+func addSecret(s string) {
+	if len(s) < minRedactedLength {
+		return
+	}
+
+	secretLock.Lock()
+	defer secretLock.Unlock()
+	secrets[s] = true
+}
+
+// redactSecrets replaces every secret in b. Longer secrets are replaced
+// first so a secret containing another is redacted whole.
+func redactSecrets(b []byte) []byte {
+	secretLock.RLock()
+	defer secretLock.RUnlock()
+
+	if len(secrets) == 0 {
+		return b
+	}
+
+	sorted := make([]string, 0, len(secrets))
+	for s := range secrets {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	for _, s := range sorted {
+		b = bytes.ReplaceAll(b, []byte(s), []byte(redacted))
+
+		// As the JSON formatter would write it
+		if j, err := json.Marshal(s); err == nil {
+			if escaped := j[1 : len(j)-1]; !bytes.Equal(escaped, []byte(s)) {
+				b = bytes.ReplaceAll(b, escaped, []byte(redacted))
+			}
+		}
+	}
+	return b
+}
+
+// redactingFormatter keeps secrets out of the log.
+type redactingFormatter struct {
+	logrus.Formatter
+}
+
+func (f *redactingFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	b, err := f.Formatter.Format(e)
+	if err != nil {
+		return nil, err
+	}
+	return redactSecrets(b), nil
+}
+
+// DumpConfig writes the config as JSON, with its included files merged in and
+// its module configs' secrets redacted.
+func DumpConfig(w io.Writer, c *Config) error {
+	dump := *c
+	dump.ModuleConfig = c.redactedModuleConfig
+	dump.Include = nil
+
+	b, err := json.MarshalIndent(&dump, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(redactSecrets(b), '\n'))
+	return err
+}
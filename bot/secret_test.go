@@ -0,0 +1,187 @@
+package bot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// resetSecrets forgets the secrets registered by earlier tests.
+func resetSecrets() {
+	secretLock.Lock()
+	secrets = make(map[string]bool)
+	secretLock.Unlock()
+}
+
+func TestResolveValue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("ERIKBOTDEV_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("ERIKBOTDEV_TEST_SECRET")
+	os.Unsetenv("ERIKBOTDEV_TEST_UNSET")
+	defer resetSecrets()
+
+	tests := []struct {
+		name     string
+		key      string
+		value    string
+		mode     resolveMode
+		resolved string
+		hidden   string
+		err      bool
+	}{
+		{name: "plain value", key: "channel", value: "erik", mode: resolveRefs, resolved: "erik", hidden: "erik"},
+		{name: "env var", key: "clientID", value: "$ERIKBOTDEV_TEST_SECRET", mode: resolveRefs, resolved: "env-secret", hidden: "$ERIKBOTDEV_TEST_SECRET"},
+		{name: "braced env var", key: "clientID", value: "${ERIKBOTDEV_TEST_SECRET}", mode: resolveRefs, resolved: "env-secret", hidden: "${ERIKBOTDEV_TEST_SECRET}"},
+		{name: "file", key: "clientSecret", value: "${file:" + tokenFile + "}", mode: resolveRefs, resolved: "file-secret", hidden: "${file:" + tokenFile + "}"},
+		{name: "literal secret key", key: "oauthToken", value: "oauth:literal", mode: resolveRefs, resolved: "oauth:literal", hidden: redacted},
+		{name: "literal password key ignores case", key: "Password", value: "hunter22", mode: resolveRefs, resolved: "hunter22", hidden: redacted},
+		{name: "reference only when whole value", key: "message", value: "costs $5 or $ERIKBOTDEV_TEST_SECRET", mode: resolveRefs, resolved: "costs $5 or $ERIKBOTDEV_TEST_SECRET", hidden: "costs $5 or $ERIKBOTDEV_TEST_SECRET"},
+		{name: "unset env var", key: "clientID", value: "$ERIKBOTDEV_TEST_UNSET", mode: resolveRefs, err: true},
+		{name: "missing file", key: "clientID", value: "${file:" + filepath.Join(dir, "missing") + "}", mode: resolveRefs, err: true},
+		{name: "disabled module keeps reference", key: "clientID", value: "$ERIKBOTDEV_TEST_SECRET", resolved: "$ERIKBOTDEV_TEST_SECRET", hidden: "$ERIKBOTDEV_TEST_SECRET"},
+		{name: "disabled module redacts literal secret", key: "password", value: "hunter22", resolved: "hunter22", hidden: redacted},
+		{name: "lenient resolves set env var", key: "clientID", value: "$ERIKBOTDEV_TEST_SECRET", mode: resolveSetRefs, resolved: "env-secret", hidden: "$ERIKBOTDEV_TEST_SECRET"},
+		{name: "lenient keeps unset env var", key: "clientID", value: "$ERIKBOTDEV_TEST_UNSET", mode: resolveSetRefs, resolved: "$ERIKBOTDEV_TEST_UNSET", hidden: "$ERIKBOTDEV_TEST_UNSET"},
+		{name: "lenient keeps missing file", key: "token", value: "${file:" + filepath.Join(dir, "missing") + "}", mode: resolveSetRefs, resolved: "${file:" + filepath.Join(dir, "missing") + "}", hidden: "${file:" + filepath.Join(dir, "missing") + "}"},
+		{name: "lenient redacts literal secret", key: "password", value: "hunter22", mode: resolveSetRefs, resolved: "hunter22", hidden: redacted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, hidden, err := resolveValue("moduleConfig.test", tt.key, tt.value, tt.mode)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", resolved)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved != tt.resolved {
+				t.Errorf("resolved %q, want %q", resolved, tt.resolved)
+			}
+			if hidden != tt.hidden {
+				t.Errorf("hidden %q, want %q", hidden, tt.hidden)
+			}
+		})
+	}
+}
+
+func TestResolveValueNested(t *testing.T) {
+	os.Setenv("ERIKBOTDEV_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("ERIKBOTDEV_TEST_SECRET")
+	defer resetSecrets()
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(`{"accounts": [{"name": "a", "token": "literal"}, {"name": "b", "token": "$ERIKBOTDEV_TEST_SECRET"}], "port": 4444}`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, hidden, err := resolveValue("moduleConfig.test", "", v, resolveRefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantResolved := `{"accounts":[{"name":"a","token":"literal"},{"name":"b","token":"env-secret"}],"port":4444}`
+	wantHidden := `{"accounts":[{"name":"a","token":"[REDACTED]"},{"name":"b","token":"$ERIKBOTDEV_TEST_SECRET"}],"port":4444}`
+
+	for _, c := range []struct {
+		got  interface{}
+		want string
+	}{{resolved, wantResolved}, {hidden, wantHidden}} {
+		j, err := json.Marshal(c.got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(j) != c.want {
+			t.Errorf("got %s, want %s", j, c.want)
+		}
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []string
+		in      string
+		out     string
+	}{
+		{name: "no secrets", in: "nothing to hide", out: "nothing to hide"},
+		{name: "every occurrence", secrets: []string{"abcd1234"}, in: "abcd1234 and abcd1234", out: "[REDACTED] and [REDACTED]"},
+		{name: "longest first", secrets: []string{"abcd", "abcdefgh"}, in: "x abcdefgh y", out: "x [REDACTED] y"},
+		{name: "too short to redact", secrets: []string{"abc"}, in: "abc", out: "abc"},
+		{name: "JSON escaped form", secrets: []string{`pa"ss\word`}, in: `{"msg":"pa\"ss\\word"}`, out: `{"msg":"[REDACTED]"}`},
+		{name: "JSON escaped HTML", secrets: []string{"a<b>&c"}, in: `{"msg":"a<b>&c"}`, out: `{"msg":"[REDACTED]"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetSecrets()
+			defer resetSecrets()
+
+			for _, s := range tt.secrets {
+				addSecret(s)
+			}
+			if got := string(redactSecrets([]byte(tt.in))); got != tt.out {
+				t.Errorf("got %q, want %q", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dotenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".env")
+	env := "# comment\n\nERIKBOTDEV_TEST_A=plain\nexport ERIKBOTDEV_TEST_B=\"quoted value\"\nERIKBOTDEV_TEST_C='single'\nERIKBOTDEV_TEST_SET=from-file\n"
+	if err := ioutil.WriteFile(path, []byte(env), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("ERIKBOTDEV_TEST_SET", "from-env")
+	defer func() {
+		for _, k := range []string{"A", "B", "C", "SET"} {
+			os.Unsetenv("ERIKBOTDEV_TEST_" + k)
+		}
+	}()
+
+	if err := LoadDotEnv(path); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"ERIKBOTDEV_TEST_A":   "plain",
+		"ERIKBOTDEV_TEST_B":   "quoted value",
+		"ERIKBOTDEV_TEST_C":   "single",
+		"ERIKBOTDEV_TEST_SET": "from-env",
+	}
+	got := make(map[string]string)
+	for k := range want {
+		got[k] = os.Getenv(k)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := LoadDotEnv(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("a missing .env file should be ignored, got %s", err)
+	}
+}
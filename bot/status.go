@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"strconv"
+	"sync"
+)
+
+// status is written by whichever module tracks the stream, e.g. OBS, and
+// read from command goroutines, so it is only accessed through its methods.
+type status struct {
+	lock      sync.RWMutex
+	streaming bool
+	scene     string
+}
+
+func (s *status) Streaming() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.streaming
+}
+
+func (s *status) Scene() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.scene
+}
+
+// RestoreStatus sets the status the bot starts with without publishing any
+// events, restarting the bot mid-stream isn't a new stream.
+func RestoreStatus(streaming bool, scene string) {
+	Status.lock.Lock()
+	defer Status.lock.Unlock()
+
+	Status.streaming = streaming
+	Status.scene = scene
+}
+
+// SetStreaming updates the streaming status, publishing a StreamStartedEvent
+// or StreamStoppedEvent when it changes.
+func SetStreaming(streaming bool) {
+	Status.lock.Lock()
+	changed := streaming != Status.streaming
+	Status.streaming = streaming
+	Status.lock.Unlock()
+
+	if !changed {
+		return
+	}
+	if streaming {
+		Publish(StreamStartedEvent{})
+	} else {
+		Publish(StreamStoppedEvent{})
+	}
+}
+
+// SetScene updates the current scene, publishing a SceneChangedEvent when it
+// changes.
+func SetScene(scene string) {
+	Status.lock.Lock()
+	old := Status.scene
+	Status.scene = scene
+	Status.lock.Unlock()
+
+	if scene != old {
+		Publish(SceneChangedEvent{Old: old, New: scene})
+	}
+}
+
+func fireStreamingTrigger(name string, streaming bool) {
+	RunTrigger(name, Params{
+		Channel: getMainChannel(),
+		Command: name,
+		Payload: map[string]string{
+			"old": strconv.FormatBool(!streaming),
+			"new": strconv.FormatBool(streaming),
+		},
+	})
+}
@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+const streamingTriggers = `{"triggers": {
+	"bot::StreamStarted": {"actions": [{"name": "test::Record", "args": {"name": "started {{payload.old}} {{payload.new}}"}}]},
+	"bot::StreamStopped": {"actions": [{"name": "test::Record", "args": {"name": "stopped {{payload.old}} {{payload.new}}"}}]}
+}}`
+
+func TestSetStreaming(t *testing.T) {
+	tests := []struct {
+		name string
+		from bool
+		to   bool
+		ran  []string
+	}{
+		{name: "started", from: false, to: true, ran: []string{"started false true"}},
+		{name: "stopped", from: true, to: false, ran: []string{"stopped true false"}},
+		{name: "still streaming", from: true, to: true},
+		{name: "still offline", from: false, to: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, streamingTriggers)
+			useStreaming(t, tt.from)
+
+			SetStreaming(tt.to)
+			WaitForCommands(time.Second)
+
+			if Status.Streaming() != tt.to {
+				t.Errorf("streaming %t, want %t", Status.Streaming(), tt.to)
+			}
+			if got := ranActions.take(); !sameItems(got, tt.ran) {
+				t.Errorf("ran %v, want %v", got, tt.ran)
+			}
+		})
+	}
+}
+
+func TestSetScene(t *testing.T) {
+	var changes recorder
+	Subscribe(func(e SceneChangedEvent) {
+		changes.add(e.Old + " -> " + e.New)
+	})
+
+	useStreaming(t, false)
+	RestoreStatus(false, "Starting")
+
+	for _, scene := range []string{"Starting", "Coding", "Coding", "Ending"} {
+		SetScene(scene)
+	}
+
+	want := []string{"Starting -> Coding", "Coding -> Ending"}
+	if got := changes.take(); !sameItems(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if Status.Scene() != "Ending" {
+		t.Errorf("scene %q, want Ending", Status.Scene())
+	}
+}
+
+// Restarting the bot mid-stream isn't a new stream.
+func TestRestoreStatus(t *testing.T) {
+	useTestConfig(t, streamingTriggers)
+	useStreaming(t, false)
+
+	RestoreStatus(true, "Coding")
+	WaitForCommands(time.Second)
+
+	if !Status.Streaming() || Status.Scene() != "Coding" {
+		t.Errorf("got %t %q, want true Coding", Status.Streaming(), Status.Scene())
+	}
+	if got := ranActions.take(); len(got) != 0 {
+		t.Errorf("ran %v", got)
+	}
+}
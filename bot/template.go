@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var templateVar = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// templateContext resolves template variables for a single render. The user
+// record is only loaded from the database if the template asks for it.
+type templateContext struct {
+	cmd        Params
+	extra      map[string]string
+	user       *User
+	userLoaded bool
+}
+
+// RenderTemplate replaces {{variable}} placeholders in s. Supported variables:
+//
+//	user, user.id, user.name, user.color, points
+//	channel, command, args, args.N
+//	payload.KEY, counter.NAME
+//	status.streaming, status.scene
+//
+// Any values in extra are also available by key. Unknown variables render as
+// an empty string.
+func RenderTemplate(s string, cmd Params, extra map[string]string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+
+	t := &templateContext{cmd: cmd, extra: extra}
+	return templateVar.ReplaceAllStringFunc(s, func(match string) string {
+		key := templateVar.FindStringSubmatch(match)[1]
+		return t.lookup(key)
+	})
+}
+
+// renderArgs returns a copy of args with every value rendered. Config args
+// are never modified in place.
+func renderArgs(args map[string]string, cmd Params) map[string]string {
+	rendered := make(map[string]string, len(args))
+	for k, v := range args {
+		rendered[k] = RenderTemplate(v, cmd, nil)
+	}
+	return rendered
+}
+
+func (t *templateContext) lookup(key string) string {
+	if v, ok := t.extra[key]; ok {
+		return v
+	}
+
+	prefix, name := key, ""
+	if i := strings.Index(key, "."); i >= 0 {
+		prefix, name = key[:i], key[i+1:]
+	}
+
+	switch prefix {
+	case "user":
+		switch name {
+		case "", "name":
+			return t.cmd.UserName
+		case "id":
+			return t.cmd.UserID
+		case "color":
+			if u := t.getUser(); u != nil {
+				return u.Color
+			}
+		}
+	case "points":
+		if u := t.getUser(); u != nil {
+			return strconv.FormatUint(u.Points, 10)
+		}
+	case "channel":
+		return t.cmd.Channel
+	case "command":
+		return t.cmd.Command
+	case "args":
+		if name == "" {
+			return strings.Join(t.cmd.CommandArgs, " ")
+		}
+		if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(t.cmd.CommandArgs) {
+			return t.cmd.CommandArgs[i]
+		}
+	case "payload":
+		return t.cmd.Payload[name]
+	case "counter":
+		if db != nil && name != "" {
+			return strconv.FormatUint(GetCounter(name), 10)
+		}
+	case "status":
+		switch name {
+		case "streaming":
+			return strconv.FormatBool(Status.Streaming())
+		case "scene":
+			return Status.Scene()
+		}
+	}
+
+	return ""
+}
+
+func (t *templateContext) getUser() *User {
+	if !t.userLoaded {
+		t.userLoaded = true
+		if db != nil && t.cmd.UserID != "" {
+			if u, err := GetUser(t.cmd.UserID); err == nil {
+				t.user = u
+			}
+		}
+	}
+	return t.user
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	openTestDatabase(t)
+
+	u, err := GetUser("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Color = "#ff0000"
+	u.Points = 42
+	if err := u.Save(); err != nil {
+		t.Fatal(err)
+	}
+	SetCounter("deaths", 3)
+
+	cmd := Params{
+		Command:     "so",
+		Channel:     "erikdotdev",
+		UserID:      "1",
+		UserName:    "Erik",
+		CommandArgs: []string{"bob", "now"},
+		Payload:     map[string]string{"reward": "Hydrate"},
+	}
+
+	tests := []struct {
+		in    string
+		extra map[string]string
+		want  string
+	}{
+		{in: "no variables", want: "no variables"},
+		{in: "hi {{user}}", want: "hi Erik"},
+		{in: "{{ user.name }} ({{user.id}})", want: "Erik (1)"},
+		{in: "{{user.color}} {{points}}", want: "#ff0000 42"},
+		{in: "!{{command}} in {{channel}}", want: "!so in erikdotdev"},
+		{in: "{{args}}", want: "bob now"},
+		{in: "{{args.0}}|{{args.1}}|{{args.2}}|{{args.-1}}", want: "bob|now||"},
+		{in: "{{payload.reward}}{{payload.missing}}", want: "Hydrate"},
+		{in: "died {{counter.deaths}} times", want: "died 3 times"},
+		{in: "{{unknown}}", want: ""},
+		{in: "{{user}", want: "{{user}"},
+		{in: "{{remaining}} left", extra: map[string]string{"remaining": "5s"}, want: "5s left"},
+		{in: "{{user}}", extra: map[string]string{"user": "overridden"}, want: "overridden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := RenderTemplate(tt.in, cmd, tt.extra); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Without a database user variables render empty instead of failing.
+func TestRenderTemplateWithoutDatabase(t *testing.T) {
+	cmd := Params{UserID: "1", UserName: "Erik"}
+	if got := RenderTemplate("{{user}} {{points}}{{counter.deaths}}", cmd, nil); got != "Erik " {
+		t.Errorf("got %q, want %q", got, "Erik ")
+	}
+}
+
+func TestRenderArgs(t *testing.T) {
+	args := map[string]string{"message": "hi {{user}}", "room": "Office"}
+	got := renderArgs(args, Params{UserName: "Erik"})
+
+	if !sameMap(got, map[string]string{"message": "hi Erik", "room": "Office"}) {
+		t.Errorf("got %v", got)
+	}
+	if args["message"] != "hi {{user}}" {
+		t.Errorf("the config's args were changed to %v", args)
+	}
+}
+
+func TestFormatRemaining(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{in: 0, want: "0s"},
+		{in: -time.Second, want: "0s"},
+		{in: time.Millisecond, want: "1s"},
+		{in: 1500 * time.Millisecond, want: "2s"},
+		{in: 30 * time.Second, want: "30s"},
+		{in: 90 * time.Second, want: "1m30s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in.String(), func(t *testing.T) {
+			if got := formatRemaining(tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
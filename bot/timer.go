@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+var timerTick = 5 * time.Second
+
+// Timer runs its actions every Interval while streaming, as long as at least
+// MinChatMessages chat messages arrived since it last ran. With Rotate set
+// only one action runs each time, cycling through the list.
+type Timer struct {
+	Enabled         bool     `json:"enabled"`
+	Interval        Duration `json:"interval"`
+	MinChatMessages uint64   `json:"minChatMessages"`
+	Rotate          bool     `json:"rotate"`
+	Actions         []Action `json:"actions"`
+}
+
+type timerState struct {
+	lastRun   time.Time
+	lastCount uint64
+	next      int
+}
+
+var chatMessageCount uint64
+
+// RecordChatMessage counts a chat message towards the timers' activity
+// thresholds.
+func RecordChatMessage() {
+	atomic.AddUint64(&chatMessageCount, 1)
+}
+
+// RunTimers runs the configured timers until ctx is cancelled. Timers are
+// read from the current config on every tick so reloads apply straight away.
+func RunTimers(ctx context.Context) {
+	states := make(map[string]*timerState)
+	ticker := time.NewTicker(timerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			count := atomic.LoadUint64(&chatMessageCount)
+			for name, t := range currentConfig().Timers {
+				s, ok := states[name]
+				if !ok {
+					// Don't fire everything the moment the bot starts
+					s = &timerState{lastRun: now, lastCount: count}
+					states[name] = s
+				}
+
+				// Timers start counting when the stream does
+				if !Status.Streaming() {
+					s.lastRun = now
+					s.lastCount = count
+					continue
+				}
+
+				if !t.Enabled || len(t.Actions) == 0 {
+					continue
+				}
+				if now.Sub(s.lastRun) < time.Duration(t.Interval) || count-s.lastCount < t.MinChatMessages {
+					continue
+				}
+
+				s.lastRun = now
+				s.lastCount = count
+
+				actions := t.Actions
+				if t.Rotate {
+					actions = []Action{t.Actions[s.next%len(t.Actions)]}
+					s.next++
+				}
+				runTimer(name, actions)
+			}
+		}
+	}
+}
+
+func runTimer(name string, actions []Action) {
+	cmd := Params{
+		Channel: getMainChannel(),
+		Command: name,
+	}
+
+	runner.run(func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout(0))
+		defer cancel()
+
+		if err := runTriggerActions(ctx, Trigger{Actions: actions}, cmd); err != nil {
+			Log.WithError(err).WithField("timer", name).Error("Timer failed")
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunTimers(t *testing.T) {
+	tests := []struct {
+		name      string
+		timer     string
+		streaming bool
+		// Chat messages sent in each round, which are a few ticks apart
+		rounds []int
+		ran    []string
+	}{
+		{
+			name:      "runs after enough chat",
+			timer:     `{"enabled": true, "interval": "1ms", "minChatMessages": 3, "actions": [{"name": "test::Record", "args": {"name": "a"}}]}`,
+			streaming: true,
+			rounds:    []int{0, 2, 1, 0},
+			ran:       []string{"a"},
+		},
+		{
+			name:      "rotates",
+			timer:     `{"enabled": true, "interval": "1ms", "minChatMessages": 1, "rotate": true, "actions": [{"name": "test::Record", "args": {"name": "a"}}, {"name": "test::Record", "args": {"name": "b"}}]}`,
+			streaming: true,
+			rounds:    []int{1, 1, 1},
+			ran:       []string{"a", "b", "a"},
+		},
+		{
+			name:   "offline",
+			timer:  `{"enabled": true, "interval": "1ms", "minChatMessages": 1, "actions": [{"name": "test::Record", "args": {"name": "a"}}]}`,
+			rounds: []int{1, 1},
+		},
+		{
+			name:      "disabled",
+			timer:     `{"interval": "1ms", "minChatMessages": 1, "actions": [{"name": "test::Record", "args": {"name": "a"}}]}`,
+			streaming: true,
+			rounds:    []int{1, 1},
+		},
+	}
+
+	oldTick := timerTick
+	timerTick = 5 * time.Millisecond
+	defer func() {
+		timerTick = oldTick
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, `{"timers": {"hydrate": `+tt.timer+`}}`)
+			useStreaming(t, tt.streaming)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				RunTimers(ctx)
+				close(done)
+			}()
+
+			// Let the first tick start the timer
+			time.Sleep(4 * timerTick)
+			for _, messages := range tt.rounds {
+				for i := 0; i < messages; i++ {
+					RecordChatMessage()
+				}
+				time.Sleep(4 * timerTick)
+			}
+			cancel()
+			<-done
+			WaitForCommands(time.Second)
+
+			if got := ranActions.take(); !sameItems(got, tt.ran) {
+				t.Errorf("ran %v, want %v", got, tt.ran)
+			}
+		})
+	}
+}
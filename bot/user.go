@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/nicklaw5/helix"
+	"github.com/sirupsen/logrus"
 	"go.etcd.io/bbolt"
 )
 
@@ -18,6 +20,7 @@ func init() {
 var users *lru.Cache
 var twitchUsers *lru.Cache
 
+var channelLock sync.Mutex
 var userID string
 var mainChannel string
 
@@ -32,50 +35,137 @@ type User struct {
 	lock        sync.RWMutex
 }
 
-func (u *User) GivePoints(points uint64) error {
-	u.lock.Lock()
-	defer u.lock.Unlock()
+// InsufficientPointsError is returned when a user can't afford a debit.
+type InsufficientPointsError struct {
+	Cost    uint64
+	Balance uint64
+}
 
-	u.Points = u.Points + points
-	return updateUser(u)
+func (e *InsufficientPointsError) Error() string {
+	return fmt.Sprintf("Insufficient points: need %d, have %d", e.Cost, e.Balance)
+}
+
+// Missing returns how many more points the user needs.
+func (e *InsufficientPointsError) Missing() uint64 {
+	return e.Cost - e.Balance
 }
 
+func (u *User) GivePoints(points uint64) error {
+	return u.adjustPoints(func(balance uint64) (uint64, error) {
+		return balance + points, nil
+	})
+}
+
+// TakePoints removes up to points from the user, never going below zero.
 func (u *User) TakePoints(points uint64) error {
-	u.lock.Lock()
-	defer u.lock.Unlock()
+	return u.adjustPoints(func(balance uint64) (uint64, error) {
+		if points > balance {
+			return 0, nil
+		}
+		return balance - points, nil
+	})
+}
 
-	u.Points = u.Points - points
-	return updateUser(u)
+// DebitPoints removes points from the user, or returns an
+// *InsufficientPointsError and leaves the balance alone if they can't afford
+// it.
+func (u *User) DebitPoints(points uint64) error {
+	return u.adjustPoints(func(balance uint64) (uint64, error) {
+		if points > balance {
+			return balance, &InsufficientPointsError{Cost: points, Balance: balance}
+		}
+		return balance - points, nil
+	})
 }
 
-func (u *User) TransferPoints(points uint64, userID string) error {
+// adjustPoints reads the user's stored balance, applies f and writes the
+// result in a single transaction so concurrent changes can't be lost.
+func (u *User) adjustPoints(f func(balance uint64) (uint64, error)) error {
 	u.lock.Lock()
 	defer u.lock.Unlock()
 
-	u2, err := GetUser(userID)
+	old := u.Points
+	err := db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(USER_BUCKET)
+
+		balance, err := storedPoints(bucket, u)
+		if err != nil {
+			return err
+		}
+
+		points, err := f(balance)
+		if err != nil {
+			return err
+		}
+
+		u.Points = points
+		buf, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(u.ID), buf)
+	})
+
 	if err != nil {
+		u.Points = old
 		return err
 	}
+	u.New = false
+	return nil
+}
 
-	// If insufficient balance. Transfer remaining balance.
-	if u.Points < points {
-		points = u.Points
+func (u *User) TransferPoints(points uint64, userID string) error {
+	if userID == u.ID {
+		return nil
 	}
-	u.Points = u.Points - points
-
-	u2.Points = u2.Points + points
 
-	jsonUser1, err := json.Marshal(u)
+	u2, err := GetUser(userID)
 	if err != nil {
 		return err
 	}
 
-	jsonUser2, err := json.Marshal(u2)
-	if err != nil {
-		return err
+	// Lock in id order, two users giving to each other at the same time
+	// would otherwise each hold one lock and wait on the other forever
+	first, second := u, u2
+	if second.ID < first.ID {
+		first, second = second, first
 	}
-	return db.Update(func(tx *bbolt.Tx) error {
+	first.lock.Lock()
+	defer first.lock.Unlock()
+	second.lock.Lock()
+	defer second.lock.Unlock()
+
+	old1, old2 := u.Points, u2.Points
+	err = db.Update(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(USER_BUCKET)
+
+		balance1, err := storedPoints(bucket, u)
+		if err != nil {
+			return err
+		}
+		balance2, err := storedPoints(bucket, u2)
+		if err != nil {
+			return err
+		}
+
+		// If insufficient balance. Transfer remaining balance.
+		if balance1 < points {
+			points = balance1
+		}
+
+		u.Points = balance1 - points
+		u2.Points = balance2 + points
+
+		jsonUser1, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+
+		jsonUser2, err := json.Marshal(u2)
+		if err != nil {
+			return err
+		}
+
 		if err := bucket.Put([]byte(u.ID), jsonUser1); err != nil {
 			return err
 		}
@@ -84,6 +174,47 @@ func (u *User) TransferPoints(points uint64, userID string) error {
 		}
 		return nil
 	})
+
+	if err != nil {
+		u.Points, u2.Points = old1, old2
+	}
+	return err
+}
+
+// storedPoints returns the user's balance as saved in the database, which may
+// be newer than a cached copy of the user.
+func storedPoints(bucket *bbolt.Bucket, u *User) (uint64, error) {
+	v := bucket.Get([]byte(u.ID))
+	if len(v) == 0 {
+		return u.Points, nil
+	}
+
+	var stored struct {
+		Points uint64 `json:"points"`
+	}
+	if err := json.Unmarshal(v, &stored); err != nil {
+		return 0, err
+	}
+	return stored.Points, nil
+}
+
+// updateFromChat records how the user appears in chat, and saves a user seen
+// for the first time with their starting points. The user is shared by every
+// command they run, so it's updated under its lock.
+func (u *User) updateFromChat(cu ChatUser) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.DisplayName = cu.DisplayName
+	u.Color = cu.Color
+	u.Badges = cu.Badges
+
+	if !u.New || cu.Guest {
+		return nil
+	}
+	u.ID = cu.ID
+	u.Points = 2500
+	return updateUser(u)
 }
 
 func (u *User) Save() error {
@@ -139,7 +270,12 @@ func GetUserByName(name string) (*User, error) {
 		return GetUser(u.(helix.User).ID)
 	}
 
-	resp, err := helixClient.GetUsers(&helix.UsersParams{
+	client := GetHelixClient()
+	if client == nil {
+		return nil, fmt.Errorf("Twitch API is not available")
+	}
+
+	resp, err := client.GetUsers(&helix.UsersParams{
 		Logins: []string{name},
 	})
 	if err != nil {
@@ -155,10 +291,26 @@ func GetUserByName(name string) (*User, error) {
 }
 
 func UpdateFollowers() error {
-	fmt.Println("Update of followers started.")
-	defer fmt.Println("Update of followers finished.")
+	client := GetHelixClient()
+	if client == nil {
+		return nil
+	}
 
+	start := time.Now()
+	defer func() {
+		followerSyncDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	// Anyone missing from the last sync is a new follower, unless this is
+	// the first sync
+	var gained []helix.UserFollow
 	err := db.Update(func(tx *bbolt.Tx) error {
+		known := make(map[string]bool)
+		tx.Bucket(FOLLOWER_BUCKET).ForEach(func(id, v []byte) error {
+			known[string(id)] = true
+			return nil
+		})
+
 		if err := tx.DeleteBucket(FOLLOWER_BUCKET); err != nil {
 			return err
 		}
@@ -169,7 +321,7 @@ func UpdateFollowers() error {
 
 		cursor := ""
 		for {
-			resp, err := helixClient.GetUsersFollows(&helix.UsersFollowsParams{After: cursor, First: 100, ToID: getUserID()})
+			resp, err := client.GetUsersFollows(&helix.UsersFollowsParams{After: cursor, First: 100, ToID: getUserID()})
 			if err != nil {
 				return err
 			}
@@ -183,6 +335,10 @@ func UpdateFollowers() error {
 				if err := followers.Put([]byte(f.FromID), j); err != nil {
 					return err
 				}
+
+				if len(known) > 0 && !known[f.FromID] {
+					gained = append(gained, f)
+				}
 			}
 
 			if len(resp.Data.Follows) < 100 {
@@ -194,10 +350,22 @@ func UpdateFollowers() error {
 		return nil
 	})
 
+	if err != nil {
+		return err
+	}
+
+	for _, f := range gained {
+		Publish(FollowerGainedEvent{
+			UserID:     f.FromID,
+			UserName:   f.FromName,
+			FollowedAt: f.FollowedAt,
+		})
+	}
+
 	err = db.Update(func(tx *bbolt.Tx) error {
 		users := tx.Bucket(USER_BUCKET)
 		followers := tx.Bucket(FOLLOWER_BUCKET)
-		users.ForEach(func(id, v []byte) error {
+		return users.ForEach(func(id, v []byte) error {
 			var u User
 			err := json.Unmarshal(v, &u)
 			if err != nil {
@@ -206,22 +374,30 @@ func UpdateFollowers() error {
 
 			// Check Followers bucket to see if this id exists
 			u.IsFollower = len(followers.Get(id)) > 0
-			buf, err := json.Marshal(u)
+			buf, err := json.Marshal(&u)
 			if err != nil {
 				return err
 			}
 			return users.Put([]byte(u.ID), buf)
 		})
-		return nil
 	})
 
 	twitchUsers.Purge()
 	users.Purge()
 
+	if err == nil {
+		Log.WithFields(logrus.Fields{
+			"new":      len(gained),
+			"duration": time.Since(start).String(),
+		}).Debug("Synced followers")
+	}
 	return err
 }
 
 func getMainChannel() string {
+	channelLock.Lock()
+	defer channelLock.Unlock()
+
 	if mainChannel != "" {
 		return mainChannel
 	}
@@ -230,7 +406,7 @@ func getMainChannel() string {
 		MainChannel string `json:"mainChannel"`
 	}
 
-	if c, ok := config.ModuleConfig["twitch"]; ok {
+	if c, ok := currentConfig().ModuleConfig["twitch"]; ok {
 		var tc twitchConfig
 		if err := json.Unmarshal(c, &tc); err == nil {
 			mainChannel = tc.MainChannel
@@ -241,13 +417,28 @@ func getMainChannel() string {
 }
 
 func getUserID() string {
+	channel := getMainChannel()
+
+	channelLock.Lock()
+	defer channelLock.Unlock()
+
 	if userID != "" {
 		return userID
 	}
 
-	if u, err := GetUserByName(getMainChannel()); err == nil {
+	if u, err := GetUserByName(channel); err == nil {
 		userID = u.ID
 	}
 
 	return userID
 }
+
+// resetChannelCache forgets the main channel and its user id so they are
+// looked up again from the current config.
+func resetChannelCache() {
+	channelLock.Lock()
+	defer channelLock.Unlock()
+
+	mainChannel = ""
+	userID = ""
+}
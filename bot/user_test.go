@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestUpdateFromChat(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   ChatUser
+		saved  bool
+		points uint64
+	}{
+		{name: "new user", user: ChatUser{ID: "1", DisplayName: "Erik", Color: "#ff0000"}, saved: true, points: 2500},
+		{name: "guest", user: ChatUser{ID: "irc:guest:erik", DisplayName: "erik", Guest: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDatabase(t)
+
+			u, err := GetUser(tt.user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := u.updateFromChat(tt.user); err != nil {
+				t.Fatal(err)
+			}
+			if u.DisplayName != tt.user.DisplayName || u.Color != tt.user.Color {
+				t.Errorf("got %q %q, want %q %q", u.DisplayName, u.Color, tt.user.DisplayName, tt.user.Color)
+			}
+
+			users.Purge()
+			stored, err := GetUser(tt.user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.New == tt.saved {
+				t.Errorf("saved %t, want %t", !stored.New, tt.saved)
+			}
+			if stored.Points != tt.points {
+				t.Errorf("points %d, want %d", stored.Points, tt.points)
+			}
+		})
+	}
+}
+
+// Run with -race, chat messages update the user while their commands change
+// its points.
+func TestUpdateFromChatConcurrently(t *testing.T) {
+	openTestDatabase(t)
+
+	u, err := GetUser("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			u.updateFromChat(ChatUser{ID: "1", DisplayName: "Erik", Badges: map[string]int{"vip": 1}})
+		}()
+		go func() {
+			defer wg.Done()
+			u.GivePoints(10)
+		}()
+	}
+	wg.Wait()
+
+	if u.Points < 100 {
+		t.Errorf("points %d, want at least 100", u.Points)
+	}
+}
+
+func TestDebitPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance uint64
+		debit   uint64
+		want    uint64
+		missing uint64
+	}{
+		{name: "affordable", balance: 100, debit: 30, want: 70},
+		{name: "everything", balance: 100, debit: 100, want: 0},
+		{name: "insufficient", balance: 20, debit: 30, want: 20, missing: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDatabase(t)
+			u := setPoints(t, "1", tt.balance)
+
+			err := u.DebitPoints(tt.debit)
+			if tt.missing > 0 {
+				e, ok := err.(*InsufficientPointsError)
+				if !ok {
+					t.Fatalf("got %v, want an *InsufficientPointsError", err)
+				}
+				if e.Missing() != tt.missing {
+					t.Errorf("missing %d, want %d", e.Missing(), tt.missing)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if u.Points != tt.want {
+				t.Errorf("balance %d, want %d", u.Points, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferPoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     uint64
+		to       uint64
+		transfer uint64
+		wantFrom uint64
+		wantTo   uint64
+	}{
+		{name: "affordable", from: 100, to: 10, transfer: 30, wantFrom: 70, wantTo: 40},
+		{name: "more than they have", from: 20, to: 10, transfer: 30, wantFrom: 0, wantTo: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDatabase(t)
+			from := setPoints(t, "1", tt.from)
+			to := setPoints(t, "2", tt.to)
+
+			if err := from.TransferPoints(tt.transfer, "2"); err != nil {
+				t.Fatal(err)
+			}
+			if from.Points != tt.wantFrom || to.Points != tt.wantTo {
+				t.Errorf("balances %d and %d, want %d and %d", from.Points, to.Points, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+// Run with -race, two users giving to each other at once mustn't deadlock.
+func TestTransferPointsBothWays(t *testing.T) {
+	openTestDatabase(t)
+	u1 := setPoints(t, "1", 1000)
+	u2 := setPoints(t, "2", 1000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			u1.TransferPoints(1, "2")
+		}()
+		go func() {
+			defer wg.Done()
+			u2.TransferPoints(1, "1")
+		}()
+	}
+	wg.Wait()
+
+	if u1.Points+u2.Points != 2000 {
+		t.Errorf("balances %d and %d don't add up to 2000", u1.Points, u2.Points)
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+	"github.com/spf13/cobra"
+)
+
+var auditFilter bot.AuditFilter
+var auditSince string
+var auditTailLines int
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "commands for reading the audit log of commands and triggers run",
+	Long: `Every command and configured trigger the bot runs is recorded in the audit log, along with who ran it,
+the points it cost and whether it worked. The database can only be opened by one process at a time, so stop
+the bot first.`,
+}
+
+var auditTailCmd = &cobra.Command{
+	Use:         "tail",
+	Short:       "Show the latest audit entries",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{configOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printAudit(bot.AuditFilter{Limit: auditTailLines})
+	},
+}
+
+var auditSearchCmd = &cobra.Command{
+	Use:         "search",
+	Short:       "Show the audit entries for a user, command or time",
+	Example:     "  erikbotdev audit search --user erikdotdev --command lights --since 30m",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{configOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f := auditFilter
+		if auditSince != "" {
+			since, err := parseSince(auditSince)
+			if err != nil {
+				return err
+			}
+			f.Since = since
+		}
+		return printAudit(f)
+	},
+}
+
+var auditPruneCmd = &cobra.Command{
+	Use:         "prune",
+	Short:       "Delete audit entries older than the configured retention",
+	Long:        "Delete audit entries older than the configured retention. The bot does this every hour while it runs.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{configOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openDatabase(); err != nil {
+			return err
+		}
+		defer bot.CloseDatabase()
+
+		n, err := bot.PruneAudit()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %d audit entries\n", n)
+		return nil
+	},
+}
+
+// parseSince accepts a duration like 20m, meaning that long ago, or a time.
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid --since '%s', use a duration like 20m or a time like 2006-01-02 15:04", s)
+}
+
+func printAudit(f bot.AuditFilter) error {
+	if err := openDatabase(); err != nil {
+		return err
+	}
+	defer bot.CloseDatabase()
+
+	entries, err := bot.SearchAudit(f)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, e := range entries {
+		name := e.Command
+		if e.Kind == bot.AuditCommand {
+			name = strings.TrimSpace("!" + name + " " + strings.Join(e.Args, " "))
+		}
+
+		user := e.UserName
+		if user == "" {
+			user = "-"
+		}
+
+		result := "ok"
+		if !e.Success {
+			result = "error: " + e.Error
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			e.Time.Local().Format("2006-01-02 15:04:05"), user, name, e.Points, e.Duration.Round(time.Millisecond), result)
+	}
+	return w.Flush()
+}
+
+func initAuditCmd() {
+	auditTailCmd.Flags().IntVarP(&auditTailLines, "lines", "n", 20, "Number of entries to show")
+
+	auditSearchCmd.Flags().StringVar(&auditFilter.User, "user", "", "User name or id")
+	auditSearchCmd.Flags().StringVar(&auditFilter.Command, "command", "", "Command or trigger name")
+	auditSearchCmd.Flags().StringVar(&auditSince, "since", "", "Only entries since a duration ago, e.g. 20m, or a time, e.g. 2006-01-02 15:04")
+	auditSearchCmd.Flags().IntVarP(&auditFilter.Limit, "lines", "n", 0, "Only show the latest entries, 0 shows all")
+
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditTailCmd)
+	auditCmd.AddCommand(auditSearchCmd)
+	auditCmd.AddCommand(auditPruneCmd)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "commands for working with the config file",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:         "validate [file]",
+	Short:       "Check a config file for mistakes",
+	Long:        `Checks every command and trigger against the registered actions and reports unknown actions, missing or invalid arguments and unknown trigger names. Defaults to the config file the bot would use.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{skipInit: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		path := configFile
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		c, err := bot.ParseConfigFile(path)
+		if err != nil {
+			fmt.Printf("%s: %s\n", path, err)
+			os.Exit(1)
+		}
+
+		errs := bot.ValidateConfig(c)
+		for _, err := range errs {
+			fmt.Printf("%s: %s\n", path, err)
+		}
+
+		if len(errs) > 0 {
+			fmt.Printf("%d problem(s) found\n", len(errs))
+			os.Exit(1)
+		}
+		fmt.Printf("%s: OK\n", path)
+	},
+}
+
+var configDumpCmd = &cobra.Command{
+	Use:         "dump [file]",
+	Short:       "Print a config file as the bot reads it",
+	Long:        `Prints the config as JSON with module config secrets redacted. Secrets referenced as $VAR or ${file:/path} are shown as their reference. Defaults to the config file the bot would use.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{skipInit: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configFile
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		c, err := bot.ParseConfigFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		return bot.DumpConfig(os.Stdout, c)
+	},
+}
+
+func initConfigCmd() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configDumpCmd)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+	_ "github.com/erikstmartin/erikbotdev/modules/bot"
+)
+
+func TestValidateExamplesWithoutSecrets(t *testing.T) {
+	for _, name := range []string{"TWITCH_CLIENT_ID", "TWITCH_CLIENT_SECRET", "TWITCH_OAUTH_TOKEN", "HUE_USER"} {
+		if v, ok := os.LookupEnv(name); ok {
+			os.Unsetenv(name)
+			defer os.Setenv(name, v)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// aaronbot5000.json uses actions of modules it doesn't enable, which
+		// validate reports, but it must still parse
+		valid bool
+	}{
+		{name: "erikbotdev.json", valid: true},
+		{name: "simple.json", valid: true},
+		{name: "aaronbot5000.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := bot.ParseConfigFile(filepath.Join("..", "examples", tt.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.valid {
+				for _, err := range bot.ValidateConfig(c) {
+					t.Error(err)
+				}
+			}
+
+			if err := bot.DumpConfig(ioutil.Discard, c); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
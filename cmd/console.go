@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+	"github.com/erikstmartin/erikbotdev/modules/twitch"
+	"github.com/spf13/cobra"
+)
+
+var consoleUser twitch.ConsoleUser
+var consoleBadges []string
+var consoleStreaming bool
+
+var consoleCmd = &cobra.Command{
+	Use:   "console",
+	Short: "Chat with the bot from the terminal",
+	Long: `Reads chat messages from stdin, one per line, and handles them as if they were sent to the main channel.
+Anything the bot says in chat is printed instead. Twitch chat is never connected, so commands can be tried
+without going live. Other enabled modules still run, so use a separate config to keep the lights off.`,
+	Annotations: map[string]string{configOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		badges, err := parseBadges(consoleBadges)
+		if err != nil {
+			return err
+		}
+		consoleUser.Badges = badges
+
+		if err := openDatabase(); err != nil {
+			return err
+		}
+		defer bot.CloseDatabase()
+
+		bot.InitModules()
+		if err := bot.InitTwitchAPI(); err != nil {
+			bot.Log.WithError(err).Warn("Twitch API is not available, commands that use it will fail")
+		}
+
+		bot.StartPlugins()
+		defer bot.StopPlugins(5 * time.Second)
+
+		bot.RestoreStatus(consoleStreaming, "")
+
+		if err := twitch.RunConsole(os.Stdin, os.Stdout, consoleUser); err != nil {
+			return err
+		}
+
+		// Let the last commands finish before exiting
+		if !bot.WaitForCommands(30 * time.Second) {
+			bot.CancelAll()
+		}
+		return nil
+	},
+}
+
+// parseBadges turns badges like "moderator" or "subscriber=12" into the form
+// Twitch sends them in.
+func parseBadges(list []string) (map[string]int, error) {
+	badges := make(map[string]int)
+	for _, b := range list {
+		parts := strings.SplitN(b, "=", 2)
+
+		version := 1
+		if len(parts) == 2 {
+			var err error
+			if version, err = strconv.Atoi(parts[1]); err != nil {
+				return nil, err
+			}
+		}
+		badges[parts[0]] = version
+	}
+	return badges, nil
+}
+
+func initConsoleCmd() {
+	consoleCmd.Flags().StringVar(&consoleUser.Name, "user", "console", "Name of the user chatting")
+	consoleCmd.Flags().StringVar(&consoleUser.ID, "user-id", "console", "Twitch user id of the user chatting")
+	consoleCmd.Flags().StringSliceVar(&consoleBadges, "badges", []string{"broadcaster"}, "Badges of the user chatting, e.g. moderator,subscriber=12")
+	consoleCmd.Flags().BoolVar(&consoleStreaming, "streaming", true, "Whether to consider the stream on")
+
+	rootCmd.AddCommand(consoleCmd)
+}
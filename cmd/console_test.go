@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBadges(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want map[string]int
+		err  bool
+	}{
+		{in: nil, want: map[string]int{}},
+		{in: []string{"broadcaster"}, want: map[string]int{"broadcaster": 1}},
+		{in: []string{"moderator", "subscriber=12"}, want: map[string]int{"moderator": 1, "subscriber": 12}},
+		{in: []string{"subscriber=twelve"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.in, ","), func(t *testing.T) {
+			got, err := parseBadges(tt.in)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	bothttp "github.com/erikstmartin/erikbotdev/http"
+	"github.com/spf13/cobra"
+)
+
+var healthAddr string
+
+var healthCmd = &cobra.Command{
+	Use:         "health",
+	Short:       "Show the health of a running bot's modules",
+	Long:        "Show the health of a running bot's modules. Exits with status 1 if any module is unhealthy or the bot can't be reached.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipInit: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Get("http://" + healthAddr + "/health")
+		if err != nil {
+			return fmt.Errorf("Is the bot running? %s", err)
+		}
+		defer resp.Body.Close()
+
+		var health bothttp.HealthResponse
+		if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, m := range health.Modules {
+			status := "ok"
+			if !m.Healthy {
+				status = "unhealthy: " + m.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\n", m.Name, status)
+		}
+		w.Flush()
+
+		if !health.Healthy {
+			return fmt.Errorf("Some modules are unhealthy")
+		}
+		return nil
+	},
+}
+
+func initHealthCmd() {
+	healthCmd.Flags().StringVar(&healthAddr, "addr", "localhost:8080", "Address of the bot's web server")
+
+	rootCmd.AddCommand(healthCmd)
+}
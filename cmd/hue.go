@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/erikstmartin/erikbotdev/modules/hue"
@@ -17,18 +18,19 @@ var hueCreateUserCmd = &cobra.Command{
 	Use:   "user-create",
 	Short: "commands for configuring hue lights",
 	Long:  `TODO: fix me`,
-	Run: func(cmd *cobra.Command, args []string) {
+	// Creating the user is how HUE_USER gets set
+	Annotations: map[string]string{unresolvedSecrets: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// TODO: This is where our server code will go
 		if len(args) == 0 {
-			fmt.Println("You must supply a username")
-			return
+			return fmt.Errorf("You must supply a username")
 		}
 		user, err := hue.CreateUser(args[0])
 		if err != nil {
-			fmt.Println("Error creating user", err)
-			return
+			return fmt.Errorf("Error creating user: %s", err)
 		}
 		fmt.Printf("User created. Please save this to an environment variable HUE_USER='%s'\n", user)
+		return nil
 	},
 }
 
@@ -36,15 +38,16 @@ var hueBridgeListCmd = &cobra.Command{
 	Use:   "bridge-list",
 	Short: "List Hue bridges",
 	Long:  `TODO: fix me`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		bridges, err := hue.ListBridges()
 		if err != nil {
-			fmt.Println("Error retrieving list of bridges", err)
+			return fmt.Errorf("Error retrieving list of bridges: %s", err)
 		}
 
 		for _, b := range bridges {
 			fmt.Println(b)
 		}
+		return nil
 	},
 }
 
@@ -52,15 +55,16 @@ var hueLightListCmd = &cobra.Command{
 	Use:   "light-list",
 	Short: "List Hue lights",
 	Long:  `TODO: fix me`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		lights, err := hue.ListLights()
 		if err != nil {
-			fmt.Println("Error retrieving list of lights", err)
+			return fmt.Errorf("Error retrieving list of lights: %s", err)
 		}
 
 		for _, l := range lights {
 			fmt.Println(l)
 		}
+		return nil
 	},
 }
 
@@ -68,15 +72,16 @@ var hueRoomListCmd = &cobra.Command{
 	Use:   "room-list",
 	Short: "List Hue rooms",
 	Long:  `TODO: fix me`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		rooms, err := hue.ListRooms()
 		if err != nil {
-			fmt.Println("Error retrieving list of rooms", err)
+			return fmt.Errorf("Error retrieving list of rooms: %s", err)
 		}
 
 		for _, r := range rooms {
 			fmt.Println(r)
 		}
+		return nil
 	},
 }
 
@@ -84,22 +89,16 @@ var hueRoomHueCmd = &cobra.Command{
 	Use:   "room-hue",
 	Short: "Change hue of room",
 	Long:  `TODO: fix me`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
-			fmt.Println("Invalid arguments")
-			return
+			return fmt.Errorf("Invalid arguments")
 		}
 
 		color, err := hue.ParseColor(args[0])
 		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		err = hue.RoomHue("Office", color)
-		if err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
+		return hue.RoomHue(context.Background(), "Office", color)
 	},
 }
 
@@ -107,15 +106,16 @@ var hueZoneListCmd = &cobra.Command{
 	Use:   "zone-list",
 	Short: "List Hue zones",
 	Long:  `TODO: fix me`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		zones, err := hue.ListZones()
 		if err != nil {
-			fmt.Println("Error retrieving list of rooms", err)
+			return fmt.Errorf("Error retrieving list of zones: %s", err)
 		}
 
 		for _, z := range zones {
 			fmt.Println(z)
 		}
+		return nil
 	},
 }
 
@@ -123,11 +123,11 @@ var hueRoomAlertCmd = &cobra.Command{
 	Use:   "room-alert",
 	Short: "Flash lights in room",
 	Long:  `TODO: fix me`,
-	Run: func(cmd *cobra.Command, args []string) {
-		err := hue.RoomAlert("Office", "lselect")
-		if err != nil {
-			fmt.Println("Error alerting room", err)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := hue.RoomAlert(context.Background(), "Office", "lselect"); err != nil {
+			return fmt.Errorf("Error alerting room: %s", err)
 		}
+		return nil
 	},
 }
 
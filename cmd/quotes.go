@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+	"github.com/spf13/cobra"
+)
+
+var quotesCmd = &cobra.Command{
+	Use:   "quotes",
+	Short: "commands for managing quotes",
+}
+
+var quotesExportCmd = &cobra.Command{
+	Use:         "export [file]",
+	Short:       "Export all quotes as JSON to a file, or stdout",
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{configOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openDatabase(); err != nil {
+			return err
+		}
+		defer bot.CloseDatabase()
+
+		quotes, err := bot.ListQuotes()
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if len(args) == 1 {
+			f, err := os.Create(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(quotes)
+	},
+}
+
+var quotesImportCmd = &cobra.Command{
+	Use:         "import <file>",
+	Short:       "Import quotes from a JSON file made by export",
+	Long:        "Import quotes from a JSON file made by export. Quotes keep their numbers, replacing existing quotes with the same number. Quotes without a number are added as new quotes.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{configOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		var quotes []bot.Quote
+		if err := json.NewDecoder(f).Decode(&quotes); err != nil {
+			return fmt.Errorf("%s: %s", args[0], err)
+		}
+
+		if err := openDatabase(); err != nil {
+			return err
+		}
+		defer bot.CloseDatabase()
+
+		if err := bot.ImportQuotes(quotes); err != nil {
+			return err
+		}
+
+		fmt.Printf("Imported %d quotes\n", len(quotes))
+		return nil
+	},
+}
+
+func openDatabase() error {
+	err := bot.OpenDatabase(bot.DatabasePath(), 0600)
+	if err != nil && err.Error() == "timeout" {
+		return fmt.Errorf("Timeout opening database. Check to ensure another process does not have the database file open")
+	}
+	return err
+}
+
+func initQuotesCmd() {
+	rootCmd.AddCommand(quotesCmd)
+	quotesCmd.AddCommand(quotesExportCmd)
+	quotesCmd.AddCommand(quotesImportCmd)
+}
@@ -4,22 +4,73 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/erikstmartin/erikbotdev/bot"
 	_ "github.com/erikstmartin/erikbotdev/modules/keylight" // TODO: Remove this after we have cobra cmd
 	"github.com/spf13/cobra"
 )
 
+// Commands with this annotation run without loading the config or
+// initializing modules, e.g. to inspect a config that may not be valid.
+const skipInit = "skipInit"
+
+// Commands with this annotation load the config but don't initialize
+// modules, so they work without OBS, Hue or Twitch being reachable.
+const configOnly = "configOnly"
+
+// Commands with this annotation don't need the config's secrets, so secret
+// references that can't be resolved are kept as written.
+const unresolvedSecrets = "unresolvedSecrets"
+
+var configFile string
+var logLevel string
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 	initHueCmd()
+	initConfigCmd()
+	initScheduleCmd()
+	initQuotesCmd()
+	initConsoleCmd()
+	initAuditCmd()
+	initHealthCmd()
+
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "Log level, one of debug, info, warn or error. Overrides the config")
 }
 
 var rootCmd = &cobra.Command{
-	Use:   "erikbotdev",
-	Short: "Twitch Bot",
-	Long:  `Twitch bot for ErikDotDev`,
+	Use:           "erikbotdev",
+	Short:         "Twitch Bot",
+	Long:          `Twitch bot for ErikDotDev`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if logLevel != "" {
+			if err := bot.OverrideLogLevel(logLevel); err != nil {
+				return err
+			}
+		}
+
+		if _, ok := cmd.Annotations[skipInit]; ok {
+			return nil
+		}
+
+		if _, ok := cmd.Annotations[unresolvedSecrets]; ok {
+			bot.AllowUnresolvedSecrets()
+		}
+		if err := bot.LoadConfigFile(configFile); err != nil {
+			return err
+		}
+
+		if _, ok := cmd.Annotations[configOnly]; ok {
+			return nil
+		}
+		return bot.Init()
+	},
 }
 
-func Execute() {
+// Execute runs the CLI using the config file at path.
+func Execute(path string) {
+	configFile = path
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
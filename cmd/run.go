@@ -1,14 +1,17 @@
 package cmd
 
 import (
-	"log"
+	"context"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/erikstmartin/erikbotdev/bot"
 	"github.com/erikstmartin/erikbotdev/http"
-	"github.com/erikstmartin/erikbotdev/modules/obs"
-	"github.com/erikstmartin/erikbotdev/modules/twitch"
+	_ "github.com/erikstmartin/erikbotdev/modules/irc"
+	_ "github.com/erikstmartin/erikbotdev/modules/obs"
+	_ "github.com/erikstmartin/erikbotdev/modules/twitch"
 	"github.com/spf13/cobra"
 )
 
@@ -30,45 +33,108 @@ var runCmd = &cobra.Command{
 	Short: "run chatbot server",
 	Long:  `Use this command to start up the chatbot server.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		go http.Start(":8080", "./web")
+		go func() {
+			if err := http.Start(":8080", "./web"); err != nil {
+				bot.Log.WithError(err).Error("Overlay server stopped")
+			}
+		}()
 
 		err := bot.InitDatabase(bot.DatabasePath(), 0600)
 		if err != nil {
 			if err.Error() == "timeout" {
-				log.Fatal("Timeout opening database. Check to ensure another process does not have the database file open")
+				bot.Log.Fatal("Timeout opening database. Check to ensure another process does not have the database file open")
 			}
-			log.Fatal("Failed to initialize database: ", err)
+			bot.Log.WithError(err).Fatal("Failed to initialize database")
 		}
 
+		bot.StartPlugins()
+
+		// Cancelled on shutdown, so the chat providers disconnect cleanly
+		chatCtx, stopChat := context.WithCancel(context.Background())
+		chatStopped := make(chan struct{})
+
 		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, os.Interrupt)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-sig
+			shutdown(stopChat, chatStopped)
+			os.Exit(0)
+		}()
 
-			bot.ExecuteTrigger("bot::Shutdown", bot.Params{
-				Command: "shutdown",
-			})
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := bot.ReloadConfig(); err != nil {
+					bot.Log.WithError(err).Error("Failed to reload config")
+				}
+			}
+		}()
 
-			if bot.IsModuleEnabled("OBS") {
-				obs.Disconnect()
+		go func() {
+			if err := bot.WatchConfig(); err != nil {
+				bot.Log.WithError(err).Warn("Not watching config for changes")
 			}
-			os.Exit(0)
 		}()
 
 		// TODO: Handle scenario where startup trigger contains a twitch action
-		bot.ExecuteTrigger("bot::Startup", bot.Params{
+		if err := bot.ExecuteTrigger(context.Background(), "bot::Startup", bot.Params{
 			Command: "startup",
-		})
+		}); err != nil {
+			bot.Log.WithError(err).Error("Startup trigger failed")
+		}
 
 		if forceStreamingOn {
-			log.Printf(
+			bot.Log.Info(
 				"Bot started with '--streaming-on', forcing it into streaming status. This won't apply if you've enabled the OBS module.",
 			)
-			bot.Status.Streaming = true
+			bot.RestoreStatus(true, bot.Status.Scene())
 		}
 
-		if err := twitch.Run(); err != nil {
-			panic(err)
+		go bot.RunTimers(context.Background())
+		go bot.RunSchedules(context.Background())
+		go bot.WatchModules(context.Background())
+
+		err = bot.ConnectChatProviders(chatCtx)
+		close(chatStopped)
+		if chatCtx.Err() != nil {
+			// Shutting down, which exits once it's done
+			select {}
+		}
+		if err != nil {
+			bot.Log.WithError(err).Fatal("Failed to connect to chat")
 		}
 	},
 }
+
+// shutdown runs the shutdown trigger while everything is still up, then
+// stops commands, plugins, chat and modules in that order.
+func shutdown(stopChat context.CancelFunc, chatStopped <-chan struct{}) {
+	bot.Log.Info("Shutting down")
+	if err := bot.ExecuteTrigger(context.Background(), "bot::Shutdown", bot.Params{
+		Command: "shutdown",
+	}); err != nil {
+		bot.Log.WithError(err).Error("Shutdown trigger failed")
+	}
+
+	bot.CancelAll()
+	if !bot.WaitForCommands(5 * time.Second) {
+		bot.Log.Warn("Timed out waiting for running commands to stop")
+	}
+	bot.StopPlugins(5 * time.Second)
+
+	stopChat()
+	select {
+	case <-chatStopped:
+	case <-time.After(5 * time.Second):
+		bot.Log.Warn("Timed out waiting for chat to disconnect")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	bot.ShutdownModules(ctx)
+
+	if err := bot.CloseDatabase(); err != nil {
+		bot.Log.WithError(err).Error("Failed to close database")
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+	"github.com/spf13/cobra"
+)
+
+var scheduleCount int
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "commands for cron schedules",
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List the next fire times of schedules and cron triggers",
+	Annotations: map[string]string{configOnly: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		runs := bot.ListSchedules(scheduleCount)
+		if len(runs) == 0 {
+			fmt.Println("No schedules configured")
+			return
+		}
+
+		for _, r := range runs {
+			fmt.Printf("%s (%s)\n", r.Name, r.Cron)
+			for _, t := range r.Times {
+				fmt.Printf("  %s\n", t.Format(time.RFC1123))
+			}
+		}
+	},
+}
+
+func initScheduleCmd() {
+	scheduleListCmd.Flags().IntVarP(&scheduleCount, "count", "n", 3, "Number of fire times to show for each schedule")
+
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+}
@@ -0,0 +1,45 @@
+#!/usr/bin/env python3
+# Example erikbotdev plugin. Provides dice::Roll, which says a dice roll in
+# chat, and fires dice::Jackpot when someone rolls the maximum.
+#
+# Plugins talk JSON-RPC 2.0 over stdin and stdout, one message per line.
+# Anything written to stderr ends up in the bot's log.
+import json
+import random
+import sys
+
+
+def send(msg):
+    msg["jsonrpc"] = "2.0"
+    sys.stdout.write(json.dumps(msg) + "\n")
+    sys.stdout.flush()
+
+
+def roll(action, params):
+    sides = int(action["args"].get("sides", "6"))
+    result = random.randint(1, sides)
+    if result == sides:
+        send({"method": "trigger", "params": {"name": "Jackpot", "params": params}})
+    return {"say": "%s rolled a %d" % (params["userName"], result)}
+
+
+for line in sys.stdin:
+    msg = json.loads(line)
+    method = msg.get("method")
+
+    if method == "initialize":
+        result = {
+            "actions": [{"name": "Roll", "args": [{"name": "sides", "type": "int"}]}],
+            "triggers": ["Jackpot"],
+        }
+    elif method == "action":
+        result = roll(msg["params"]["action"], msg["params"]["params"])
+    elif method == "configure":
+        result = None
+    else:
+        # Notifications such as cancel have no id and need no answer
+        if "id" in msg:
+            send({"id": msg["id"], "error": {"code": -32601, "message": "unknown method " + method}})
+        continue
+
+    send({"id": msg["id"], "result": result})
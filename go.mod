@@ -5,11 +5,16 @@ go 1.14
 require (
 	github.com/amimof/huego v1.1.0
 	github.com/christopher-dG/go-obs-websocket v0.0.0-20200720193653-c4fed10356a5
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/gempir/go-twitch-irc/v2 v2.4.1
+	github.com/ghodss/yaml v1.0.0
 	github.com/gorilla/websocket v1.4.2
 	github.com/hashicorp/golang-lru v0.5.4
 	github.com/mitchellh/mapstructure v1.3.3 // indirect
 	github.com/nicklaw5/helix v0.6.0
+	github.com/prometheus/client_golang v1.7.1
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/sirupsen/logrus v1.6.0
 	github.com/spf13/cobra v1.0.0
 	github.com/xeonx/timeago v1.0.0-rc4
 	go.etcd.io/bbolt v1.3.5
@@ -6,10 +6,10 @@ package http
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"time"
 
+	"github.com/erikstmartin/erikbotdev/bot"
 	"github.com/gorilla/websocket"
 )
 
@@ -100,7 +100,7 @@ func (c *Client) writePump() {
 func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("Failed to upgrade websocket", err)
+		bot.Log.WithError(err).Error("Failed to upgrade websocket")
 		return
 	}
 
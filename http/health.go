@@ -0,0 +1,30 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+)
+
+// HealthResponse is served on /health, with a 503 status when any module is
+// unhealthy.
+type HealthResponse struct {
+	Healthy bool               `json:"healthy"`
+	Modules []bot.ModuleStatus `json:"modules"`
+}
+
+func serveHealth(w http.ResponseWriter, r *http.Request) {
+	resp := HealthResponse{Healthy: true, Modules: bot.ModuleHealth()}
+	for _, m := range resp.Modules {
+		if !m.Healthy {
+			resp.Healthy = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
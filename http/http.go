@@ -1,11 +1,11 @@
 package http
 
 import (
-	"log"
 	"net/http"
 	"path/filepath"
 
 	"github.com/erikstmartin/erikbotdev/bot"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var hub *Hub
@@ -14,6 +14,28 @@ func Start(addr string, webPath string) error {
 	hub = newHub()
 	go hub.run()
 
+	bot.Subscribe(func(e bot.CounterChangedEvent) {
+		hub.BroadcastMessage(&CounterMessage{
+			Name:  e.Name,
+			Value: e.Value,
+		})
+	})
+
+	bot.Subscribe(func(e bot.FollowerGainedEvent) {
+		hub.BroadcastMessage(&FollowMessage{UserName: e.UserName})
+	})
+
+	bot.Subscribe(func(e bot.ChatMessageEvent) {
+		m := e.Message
+		if e.Command || !m.MainChannel || m.Ignored || m.Text == "" {
+			return
+		}
+		BroadcastChatMessage(e.User, m.Text)
+	})
+
+	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/health", serveHealth)
+
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		serveWs(hub, w, r)
 	})
@@ -28,11 +50,20 @@ func Start(addr string, webPath string) error {
 }
 
 func BroadcastMessage(msg Message) error {
-	log.Printf("Broadcasting message %+v", msg)
+	// The overlay isn't running, e.g. in console mode
+	if hub == nil {
+		return nil
+	}
+
+	bot.Log.WithField("message", msg).Debug("Broadcasting message")
 	return hub.BroadcastMessage(msg)
 }
 
 func BroadcastChatMessage(user *bot.User, msg string) error {
+	if hub == nil {
+		return nil
+	}
+
 	m := &ChatMessage{
 		User: user,
 		Text: msg,
@@ -59,10 +59,12 @@ func (h *Hub) run() {
 				select {
 				case client.send <- message:
 				default:
+					broadcastsDropped.Inc()
 					close(client.send)
 					delete(h.clients, client)
 				}
 			}
 		}
+		websocketClients.Set(float64(len(h.clients)))
 	}
 }
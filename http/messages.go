@@ -15,6 +15,15 @@ type ChatMessage struct {
 	Text string    `json:"text"`
 }
 
+type CounterMessage struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+type FollowMessage struct {
+	UserName string `json:"userName"`
+}
+
 type RaidMessage struct {
 	UserName     string `json:"userName"`
 	PartySize    uint16 `json:"partySize"`
@@ -0,0 +1,20 @@
+package http
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	websocketClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "erikbotdev",
+		Name:      "websocket_clients",
+		Help:      "Overlay websocket clients connected.",
+	})
+
+	broadcastsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "erikbotdev",
+		Name:      "hub_broadcasts_dropped_total",
+		Help:      "Broadcasts dropped because a client was too slow to receive them.",
+	})
+)
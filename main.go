@@ -1,60 +1,49 @@
 package main
 
 import (
-	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 
-	"github.com/erikstmartin/erikbotdev/bot"
 	"github.com/erikstmartin/erikbotdev/cmd"
 	_ "github.com/erikstmartin/erikbotdev/modules/bot"
 )
 
-var configFileName string
+// The config file names looked for, in order of preference
+var configFileNames []string
 
 func init() {
-	configFileName = os.Getenv("ERIKBOTDEV_CONFIG_FILE_NAME")
-	if configFileName == "" {
-		configFileName = "config.json"
+	if name := os.Getenv("ERIKBOTDEV_CONFIG_FILE_NAME"); name != "" {
+		configFileNames = []string{name}
+	} else {
+		configFileNames = []string{"config.json", "config.yaml", "config.yml"}
 	}
-	log.Printf("Using config %s", configFileName)
 }
 
 func main() {
-	file, err := os.Open(findConfigFile())
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	err = bot.LoadConfig(file)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-
-	if err = bot.Init(); err != nil {
-		fmt.Println(err)
-		return
-	}
-
-	cmd.Execute()
+	cmd.Execute(findConfigFile())
 }
 
 func findConfigFile() string {
-	home, err := os.UserHomeDir()
-	if err == nil {
-		path := filepath.Join(home, configFileName)
-		if _, err := os.Stat(path); err == nil {
-			return path
-		}
+	if filepath.IsAbs(configFileNames[0]) {
+		return configFileNames[0]
+	}
+
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
 	}
 
-	// Check relative to binary
+	// Check relative to binary, then the working directory
 	path, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	if _, err := os.Stat(filepath.Join(path, configFileName)); err == nil {
-		return filepath.Join(path, configFileName)
+	dirs = append(dirs, path, ".")
+
+	for _, dir := range dirs {
+		for _, name := range configFileNames {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return filepath.Join(dir, name)
+			}
+		}
 	}
 
-	return filepath.Join(".", configFileName)
+	return filepath.Join(".", configFileNames[0])
 }
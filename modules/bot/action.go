@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -14,15 +15,44 @@ func init() {
 	bot.RegisterModule(bot.Module{
 		Name: "bot",
 		Actions: map[string]bot.ActionFunc{
+			"Say":       sayAction,
 			"Sleep":     sleepAction,
 			"PlaySound": playSoundAction,
 			"ShellExec": shellExecAction,
 			"ShowImage": sendImageAction,
 		},
+		ActionSpecs: map[string]bot.ActionSpec{
+			"Say": {Args: []bot.ArgSpec{
+				{Name: "message", Required: true},
+			}},
+			"Sleep": {Args: []bot.ArgSpec{
+				{Name: "duration", Type: bot.ArgDuration, Required: true},
+			}},
+			"PlaySound": {Args: []bot.ArgSpec{
+				{Name: "sound", Required: true},
+			}},
+			"ShellExec": {Args: []bot.ArgSpec{
+				{Name: "command", Required: true},
+				{Name: "passArgs", Type: bot.ArgBool},
+				{Name: "output", Type: bot.ArgBool},
+			}},
+			"ShowImage": {Args: []bot.ArgSpec{
+				{Name: "imageURL", Required: true},
+			}},
+		},
 	})
 }
 
-func sleepAction(a bot.Action, cmd bot.Params) error {
+// sayAction replies in the chat the command or trigger came from, unlike
+// twitch::Say which always goes to Twitch.
+func sayAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
+	if _, ok := a.Args["message"]; !ok {
+		return fmt.Errorf("Argument 'message' is required.")
+	}
+	return bot.Reply(ctx, cmd, a.Args["message"])
+}
+
+func sleepAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	var d string
 	var ok bool
 
@@ -35,8 +65,7 @@ func sleepAction(a bot.Action, cmd bot.Params) error {
 		return err
 	}
 
-	time.Sleep(duration)
-	return nil
+	return bot.Sleep(ctx, duration)
 }
 
 type PlaySoundMessage struct {
@@ -47,7 +76,7 @@ type ShowImageMessage struct {
 	SourceURL string `json:"src"`
 }
 
-func sendImageAction(a bot.Action, cmd bot.Params) error {
+func sendImageAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	var s string
 	var ok bool
 	if s, ok = a.Args["imageURL"]; !ok {
@@ -61,7 +90,7 @@ func sendImageAction(a bot.Action, cmd bot.Params) error {
 	return nil
 }
 
-func playSoundAction(a bot.Action, cmd bot.Params) error {
+func playSoundAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	var s string
 	var ok bool
 	if s, ok = a.Args["sound"]; !ok {
@@ -75,7 +104,7 @@ func playSoundAction(a bot.Action, cmd bot.Params) error {
 	return nil
 }
 
-func shellExecAction(a bot.Action, cmd bot.Params) error {
+func shellExecAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	var s string
 	var ok bool
 
@@ -88,14 +117,14 @@ func shellExecAction(a bot.Action, cmd bot.Params) error {
 		args = cmd.CommandArgs
 	}
 
-	shellCmd := exec.Command(s, args...)
+	shellCmd := exec.CommandContext(ctx, s, args...)
 	out, err := shellCmd.CombinedOutput()
 	if err != nil {
 		return err
 	}
 
 	if output, ok := a.Args["output"]; ok && strings.ToLower(output) == "true" {
-		return bot.TwitchSay(cmd, string(out))
+		return bot.Reply(ctx, cmd, string(out))
 	}
 	return nil
 }
@@ -5,9 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
-	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/amimof/huego"
@@ -17,6 +15,8 @@ import (
 var randColor *rand.Rand
 var sleepDuration = 200 * time.Millisecond
 
+var alertTypes = []string{"none", "select", "lselect"}
+
 var colorMap = map[string]uint16{
 	"orange": 3000,
 	"yellow": 7000,
@@ -32,13 +32,6 @@ type Config struct {
 	Bridge string `json:"bridge"`
 }
 
-func (c *Config) GetUser() string {
-	if strings.HasPrefix(c.User, "$") {
-		return os.Getenv(strings.TrimPrefix(c.User, "$"))
-	}
-	return c.User
-}
-
 var config Config
 var bridge *huego.Bridge
 
@@ -53,6 +46,33 @@ func init() {
 			"ZoneBrightness": zoneBrightnessAction,
 			"RoomBrightness": roomBrightnessAction,
 		},
+		ActionSpecs: map[string]bot.ActionSpec{
+			"RoomHue": {Args: []bot.ArgSpec{
+				{Name: "room", Required: true},
+				{Name: "hue", Required: true, Validate: validateColor},
+			}},
+			"RoomAlert": {Args: []bot.ArgSpec{
+				{Name: "room", Required: true},
+				{Name: "type", Type: bot.ArgEnum, Required: true, Enum: alertTypes},
+			}},
+			"ZoneHue": {Args: []bot.ArgSpec{
+				{Name: "zone", Required: true},
+				{Name: "hue", Required: true, Validate: validateColor},
+			}},
+			"ZoneAlert": {Args: []bot.ArgSpec{
+				{Name: "zone", Required: true},
+				{Name: "type", Type: bot.ArgEnum, Required: true, Enum: alertTypes},
+				{Name: "hue", Validate: validateColor},
+			}},
+			"ZoneBrightness": {Args: []bot.ArgSpec{
+				{Name: "zone", Required: true},
+				{Name: "brightness", Type: bot.ArgInt, Required: true, Validate: validateBrightness},
+			}},
+			"RoomBrightness": {Args: []bot.ArgSpec{
+				{Name: "room", Required: true},
+				{Name: "brightness", Type: bot.ArgInt, Required: true, Validate: validateBrightness},
+			}},
+		},
 		Init: func(c json.RawMessage) error {
 			s := rand.NewSource(time.Now().UnixNano())
 			randColor = rand.New(s)
@@ -74,7 +94,7 @@ func getBridge() (*huego.Bridge, error) {
 	}
 
 	if config.Bridge != "" {
-		return huego.New(config.Bridge, config.GetUser()), nil
+		return huego.New(config.Bridge, config.User), nil
 	}
 
 	bridges, err := huego.DiscoverAll()
@@ -90,7 +110,7 @@ func getBridge() (*huego.Bridge, error) {
 		return nil, fmt.Errorf("No Hue bridges found")
 	}
 
-	bridge = bridges[0].Login(config.GetUser())
+	bridge = bridges[0].Login(config.User)
 	return bridge, nil
 }
 
@@ -137,9 +157,9 @@ func ListZones() ([]string, error) {
 	return listGroups("Zone")
 }
 
-func getGroup(groupName string, groupType string) (huego.Group, error) {
+func getGroup(ctx context.Context, groupName string, groupType string) (huego.Group, error) {
 	var g huego.Group
-	resp, err := bridge.GetGroupsContext(context.Background())
+	resp, err := bridge.GetGroupsContext(ctx)
 	if err != nil {
 		return g, err
 	}
@@ -152,18 +172,19 @@ func getGroup(groupName string, groupType string) (huego.Group, error) {
 	return g, fmt.Errorf("Group not found: %s", groupName)
 }
 
-func groupHue(groupName string, groupType string, hue uint16) error {
-	g, err := getGroup(groupName, groupType)
+func groupHue(ctx context.Context, groupName string, groupType string, hue uint16) error {
+	g, err := getGroup(ctx, groupName, groupType)
 	if err != nil {
 		return err
 	}
 
-	err = g.Hue(hue)
-	time.Sleep(sleepDuration)
-	return err
+	if err = g.HueContext(ctx, hue); err != nil {
+		return err
+	}
+	return bot.Sleep(ctx, sleepDuration)
 }
 
-func roomHueAction(a bot.Action, cmd bot.Params) error {
+func roomHueAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	if _, ok := a.Args["room"]; !ok {
 		return fmt.Errorf("Argument 'room' is required.")
 	}
@@ -177,10 +198,10 @@ func roomHueAction(a bot.Action, cmd bot.Params) error {
 		return err
 	}
 
-	return RoomHue(a.Args["room"], color)
+	return RoomHue(ctx, a.Args["room"], color)
 }
 
-func zoneHueAction(a bot.Action, cmd bot.Params) error {
+func zoneHueAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	if _, ok := a.Args["zone"]; !ok {
 		return fmt.Errorf("Argument 'zone' is required.")
 	}
@@ -194,10 +215,10 @@ func zoneHueAction(a bot.Action, cmd bot.Params) error {
 		return err
 	}
 
-	return ZoneHue(a.Args["zone"], color)
+	return ZoneHue(ctx, a.Args["zone"], color)
 }
 
-func roomAlertAction(a bot.Action, cmd bot.Params) error {
+func roomAlertAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	if _, ok := a.Args["room"]; !ok {
 		return fmt.Errorf("Argument 'room' is required.")
 	}
@@ -205,10 +226,10 @@ func roomAlertAction(a bot.Action, cmd bot.Params) error {
 		return fmt.Errorf("Argument 'type' is required.")
 	}
 
-	return RoomAlert(a.Args["room"], a.Args["type"])
+	return RoomAlert(ctx, a.Args["room"], a.Args["type"])
 }
 
-func zoneAlertAction(a bot.Action, cmd bot.Params) error {
+func zoneAlertAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	if _, ok := a.Args["zone"]; !ok {
 		return fmt.Errorf("Argument 'zone' is required.")
 	}
@@ -220,48 +241,49 @@ func zoneAlertAction(a bot.Action, cmd bot.Params) error {
 		if err != nil {
 			return err
 		}
-		if err := ZoneHue(a.Args["zone"], color); err != nil {
+		if err := ZoneHue(ctx, a.Args["zone"], color); err != nil {
 			return err
 		}
 	}
 
-	return ZoneAlert(a.Args["zone"], a.Args["type"])
+	return ZoneAlert(ctx, a.Args["zone"], a.Args["type"])
 }
 
-func RoomHue(roomName string, hue uint16) error {
-	return groupHue(roomName, "Room", hue)
+func RoomHue(ctx context.Context, roomName string, hue uint16) error {
+	return groupHue(ctx, roomName, "Room", hue)
 }
 
-func ZoneHue(zoneName string, hue uint16) error {
-	return groupHue(zoneName, "Zone", hue)
+func ZoneHue(ctx context.Context, zoneName string, hue uint16) error {
+	return groupHue(ctx, zoneName, "Zone", hue)
 }
 
-func groupAlert(groupName string, groupType string, alertType string) error {
+func groupAlert(ctx context.Context, groupName string, groupType string, alertType string) error {
 	if alertType != "none" && alertType != "select" && alertType != "lselect" {
 		return fmt.Errorf("alert type must be one of 'none', 'select', 'lselect'")
 	}
 
-	resp, err := bridge.GetGroupsContext(context.Background())
+	resp, err := bridge.GetGroupsContext(ctx)
 	if err != nil {
 		return err
 	}
 
 	for _, g := range resp {
 		if g.Type == groupType && g.Name == groupName {
-			err := g.Alert(alertType)
-			time.Sleep(sleepDuration)
-			return err
+			if err := g.AlertContext(ctx, alertType); err != nil {
+				return err
+			}
+			return bot.Sleep(ctx, sleepDuration)
 		}
 	}
 	return fmt.Errorf("Group not found: %s", groupName)
 }
 
-func RoomAlert(roomName string, alertType string) error {
-	return groupAlert(roomName, "Room", alertType)
+func RoomAlert(ctx context.Context, roomName string, alertType string) error {
+	return groupAlert(ctx, roomName, "Room", alertType)
 }
 
-func ZoneAlert(zoneName string, alertType string) error {
-	return groupAlert(zoneName, "Zone", alertType)
+func ZoneAlert(ctx context.Context, zoneName string, alertType string) error {
+	return groupAlert(ctx, zoneName, "Zone", alertType)
 }
 
 func ListLights() ([]string, error) {
@@ -279,7 +301,7 @@ func ListLights() ([]string, error) {
 	return lights, err
 }
 
-func roomBrightnessAction(a bot.Action, cmd bot.Params) error {
+func roomBrightnessAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	if _, ok := a.Args["brightness"]; !ok {
 		return fmt.Errorf("Argument 'brightness' is required.")
 	}
@@ -294,10 +316,10 @@ func roomBrightnessAction(a bot.Action, cmd bot.Params) error {
 	}
 
 	brightness := uint8(b)
-	return GroupBrightness(a.Args["room"], "Room", brightness)
+	return GroupBrightness(ctx, a.Args["room"], "Room", brightness)
 }
 
-func zoneBrightnessAction(a bot.Action, cmd bot.Params) error {
+func zoneBrightnessAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	if _, ok := a.Args["brightness"]; !ok {
 		return fmt.Errorf("Argument 'brightness' is required.")
 	}
@@ -312,25 +334,25 @@ func zoneBrightnessAction(a bot.Action, cmd bot.Params) error {
 	}
 
 	brightness := uint8(b)
-	return GroupBrightness(a.Args["room"], "Zone", brightness)
+	return GroupBrightness(ctx, a.Args["zone"], "Zone", brightness)
 }
 
-func GroupBrightness(groupName string, groupType string, b uint8) error {
-	g, err := getGroup(groupName, "Room")
+func GroupBrightness(ctx context.Context, groupName string, groupType string, b uint8) error {
+	g, err := getGroup(ctx, groupName, groupType)
 	if err != nil {
 		return err
 	}
 
-	return g.BriContext(context.Background(), b)
+	return g.BriContext(ctx, b)
 }
 
-func ZoneBrightness(groupName string, b uint8) error {
-	g, err := getGroup(groupName, "Room")
+func ZoneBrightness(ctx context.Context, groupName string, b uint8) error {
+	g, err := getGroup(ctx, groupName, "Zone")
 	if err != nil {
 		return err
 	}
 
-	return g.BriContext(context.Background(), b)
+	return g.BriContext(ctx, b)
 }
 
 func ParseColor(color string) (uint16, error) {
@@ -354,3 +376,23 @@ func ParseColor(color string) (uint16, error) {
 
 	return colorCode, nil
 }
+
+func validateColor(color string) error {
+	if color == "rand" || color == "random" {
+		return nil
+	}
+	if _, ok := colorMap[color]; ok {
+		return nil
+	}
+	if _, err := strconv.ParseUint(color, 10, 16); err != nil {
+		return fmt.Errorf("'%s' is not a color name or a number from 0 to 65535", color)
+	}
+	return nil
+}
+
+func validateBrightness(b string) error {
+	if _, err := strconv.ParseUint(b, 10, 8); err != nil {
+		return fmt.Errorf("'%s' must be from 0 to 255", b)
+	}
+	return nil
+}
@@ -0,0 +1,491 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+	"github.com/sirupsen/logrus"
+)
+
+// Config connects the bot to a regular IRC server. IRC has no badges, so
+// Broadcaster and Moderators name who gets them. Anyone can take a nick, so
+// they don't match nicks: a plain name matches the services account the
+// server reports through the IRCv3 account-tag, and a mask like
+// erik!*@example.com matches the nick!user@host it describes.
+type Config struct {
+	Server       string   `json:"server"`
+	TLS          bool     `json:"tls"`
+	Nick         string   `json:"nick"`
+	Password     string   `json:"password"`
+	MainChannel  string   `json:"mainChannel"`
+	Channels     []string `json:"channels"`
+	Broadcaster  string   `json:"broadcaster"`
+	Moderators   []string `json:"moderators"`
+	IgnoredUsers []string `json:"ignoredUsers"`
+}
+
+func (c *Config) channels() []string {
+	channels := append([]string{}, c.Channels...)
+	if c.MainChannel != "" && !containsFold(channels, c.MainChannel) {
+		channels = append(channels, c.MainChannel)
+	}
+	return channels
+}
+
+// badges returns the badges of the user with the prefix and services
+// account, which is empty if they aren't logged in.
+func (c *Config) badges(prefix string, account string) map[string]int {
+	badges := make(map[string]int)
+	if c.Broadcaster != "" && identifies(c.Broadcaster, prefix, account) {
+		badges["broadcaster"] = 1
+	}
+	for _, m := range c.Moderators {
+		if identifies(m, prefix, account) {
+			badges["moderator"] = 1
+		}
+	}
+	return badges
+}
+
+// usesAccounts reports whether any badge is granted by services account.
+func (c *Config) usesAccounts() bool {
+	for _, name := range append([]string{c.Broadcaster}, c.Moderators...) {
+		if name != "" && !isMask(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMask(name string) bool {
+	return strings.ContainsAny(name, "!@")
+}
+
+// identifies reports whether name, an account or a mask, matches the user.
+func identifies(name string, prefix string, account string) bool {
+	if isMask(name) {
+		return matchMask(name, prefix)
+	}
+	return account != "" && strings.EqualFold(name, account)
+}
+
+// matchMask matches a nick!user@host mask, where * matches any run of
+// characters and ? any one, ignoring case.
+func matchMask(mask string, prefix string) bool {
+	pattern := regexp.QuoteMeta(strings.ToLower(mask))
+	pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+	pattern = strings.ReplaceAll(pattern, `\?`, ".")
+	ok, _ := regexp.MatchString("^"+pattern+"$", strings.ToLower(prefix))
+	return ok
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+var configLock sync.RWMutex
+var config Config
+
+func currentConfig() Config {
+	configLock.RLock()
+	defer configLock.RUnlock()
+
+	return config
+}
+
+func loadConfig(c json.RawMessage) error {
+	var newConfig Config
+	if err := json.Unmarshal(c, &newConfig); err != nil {
+		return err
+	}
+	if newConfig.Server == "" || newConfig.Nick == "" {
+		return fmt.Errorf("irc: server and nick are required")
+	}
+
+	configLock.Lock()
+	old := config
+	config = newConfig
+	configLock.Unlock()
+
+	if old.Server != "" && (old.Server != newConfig.Server || old.Nick != newConfig.Nick || old.Password != newConfig.Password) {
+		bot.Log.Warn("IRC server or credentials changed, restart the bot to apply them")
+	}
+
+	// Only does anything once connected
+	for _, ch := range newConfig.channels() {
+		if !containsFold(old.channels(), ch) {
+			client.send("JOIN %s", ch)
+		}
+	}
+	for _, ch := range old.channels() {
+		if !containsFold(newConfig.channels(), ch) {
+			client.send("PART %s", ch)
+		}
+	}
+	return nil
+}
+
+var client = &provider{}
+
+const (
+	minReconnectBackoff = 5 * time.Second
+	maxReconnectBackoff = 5 * time.Minute
+)
+
+func init() {
+	bot.RegisterChatProvider(client)
+	bot.RegisterModule(bot.Module{
+		Name: "irc",
+		Actions: map[string]bot.ActionFunc{
+			"Say": sayAction,
+		},
+		ActionSpecs: map[string]bot.ActionSpec{
+			"Say": {Args: []bot.ArgSpec{
+				{Name: "message", Required: true},
+				{Name: "channel"},
+			}},
+		},
+		Triggers: []string{
+			"irc::Chat",
+			"irc::Join",
+			"irc::Part",
+		},
+		Init:        loadConfig,
+		Reconfigure: loadConfig,
+		// The provider reconnects by itself
+		Health: client.health,
+	})
+}
+
+func sayAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
+	channel := currentConfig().MainChannel
+	if cmd.Provider == "irc" {
+		channel = cmd.Channel
+	}
+
+	if _, ok := a.Args["channel"]; ok {
+		channel = a.Args["channel"]
+	}
+
+	if _, ok := a.Args["message"]; !ok {
+		return fmt.Errorf("Argument 'message' is required.")
+	}
+	return client.Say(channel, a.Args["message"])
+}
+
+// provider is the bot.ChatProvider for IRC.
+type provider struct {
+	lock sync.Mutex
+	conn net.Conn
+
+	onMessage func(bot.ChatMessage)
+	onEvent   func(bot.ChatEvent)
+}
+
+func (p *provider) Name() string {
+	return "irc"
+}
+
+func (p *provider) MainChannel() string {
+	return currentConfig().MainChannel
+}
+
+func (p *provider) OnMessage(f func(bot.ChatMessage)) {
+	p.onMessage = f
+}
+
+func (p *provider) OnEvent(f func(bot.ChatEvent)) {
+	p.onEvent = f
+}
+
+func (p *provider) health() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.conn == nil {
+		return fmt.Errorf("Not connected to IRC")
+	}
+	return nil
+}
+
+func (p *provider) send(format string, args ...interface{}) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.conn == nil {
+		return fmt.Errorf("Not connected to IRC")
+	}
+	_, err := fmt.Fprintf(p.conn, format+"\r\n", args...)
+	return err
+}
+
+// Say sends each line of message separately, IRC messages can't contain
+// newlines.
+func (p *provider) Say(channel string, message string) error {
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		if err := p.send("PRIVMSG %s :%s", channel, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Connect stays connected until ctx is cancelled, retrying with a growing
+// backoff if the connection can't be made or drops.
+func (p *provider) Connect(ctx context.Context) error {
+	backoff := minReconnectBackoff
+	for {
+		connected := false
+		err := p.session(ctx, func() { connected = true })
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Only back off further while the server stays unreachable
+		if connected {
+			backoff = minReconnectBackoff
+		}
+
+		log := bot.Log.WithError(err).WithFields(logrus.Fields{
+			"server":  currentConfig().Server,
+			"backoff": backoff.String(),
+		})
+		if connected {
+			log.Warn("Disconnected from IRC, reconnecting")
+		} else {
+			log.Error("Failed to connect to IRC, retrying")
+		}
+
+		if err := bot.Sleep(ctx, backoff); err != nil {
+			return nil
+		}
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
+
+func (p *provider) session(ctx context.Context, onConnected func()) error {
+	c := currentConfig()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Server)
+	if err != nil {
+		return err
+	}
+	if c.TLS {
+		host, _, _ := net.SplitHostPort(c.Server)
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+
+	p.lock.Lock()
+	p.conn = conn
+	p.lock.Unlock()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.send("QUIT")
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	defer func() {
+		p.lock.Lock()
+		p.conn = nil
+		p.lock.Unlock()
+	}()
+
+	// Servers without capabilities ignore this and register us straight
+	// away, the others wait for CAP END
+	p.send("CAP REQ :account-tag")
+	if c.Password != "" {
+		p.send("PASS %s", c.Password)
+	}
+	nick := c.Nick
+	p.send("NICK %s", nick)
+	p.send("USER %s 0 * :%s", c.Nick, c.Nick)
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		tags, prefix, command, params := parseLine(strings.TrimRight(line, "\r\n"))
+		switch command {
+		case "CAP":
+			if len(params) < 2 {
+				continue
+			}
+			switch strings.ToUpper(params[1]) {
+			case "ACK":
+				p.send("CAP END")
+			case "NAK":
+				p.send("CAP END")
+				if c.usesAccounts() {
+					bot.Log.WithField("server", c.Server).Warn("IRC server doesn't support account-tag, only broadcaster and moderators given as masks get badges")
+				}
+			}
+		case "PING":
+			p.send("PONG :%s", strings.Join(params, " "))
+		case "001":
+			onConnected()
+			bot.Log.WithFields(logrus.Fields{
+				"server": c.Server,
+				"nick":   nick,
+			}).Info("Connected to IRC")
+			for _, ch := range c.channels() {
+				p.send("JOIN %s", ch)
+			}
+		case "433":
+			// Nick in use
+			nick += "_"
+			p.send("NICK %s", nick)
+		case "PRIVMSG":
+			if len(params) == 2 {
+				p.handleMessage(prefix, tags["account"], params[0], params[1])
+			}
+		case "JOIN", "PART":
+			if len(params) >= 1 && !strings.EqualFold(nickOf(prefix), nick) {
+				p.handleEvent(prefix, tags["account"], params[0], command)
+			}
+		}
+	}
+}
+
+func (p *provider) handleMessage(prefix string, account string, channel string, text string) {
+	c := currentConfig()
+
+	p.onMessage(bot.ChatMessage{
+		Provider:    "irc",
+		Channel:     channel,
+		User:        chatUser(&c, prefix, account),
+		Text:        text,
+		MainChannel: strings.EqualFold(channel, c.MainChannel),
+		Ignored:     containsFold(c.IgnoredUsers, nickOf(prefix)),
+	})
+}
+
+func (p *provider) handleEvent(prefix string, account string, channel string, command string) {
+	c := currentConfig()
+
+	name := "Join"
+	if command == "PART" {
+		name = "Part"
+	}
+
+	p.onEvent(bot.ChatEvent{
+		Provider: "irc",
+		Channel:  channel,
+		User:     chatUser(&c, prefix, account),
+		Name:     name,
+	})
+}
+
+// chatUser returns the user behind prefix. Users are identified by their
+// services account, anyone can take a nick, so users who aren't logged in
+// are guests.
+func chatUser(c *Config, prefix string, account string) bot.ChatUser {
+	nick := nickOf(prefix)
+	u := bot.ChatUser{
+		ID:          "irc:" + strings.ToLower(account),
+		Name:        strings.ToLower(nick),
+		DisplayName: nick,
+		Badges:      c.badges(prefix, account),
+	}
+
+	// "*" is how servers say a user isn't logged in
+	if account == "" || account == "*" {
+		u.ID = "irc:guest:" + strings.ToLower(nick)
+		u.Guest = true
+	}
+	return u
+}
+
+// parseLine splits a line like
+// "@account=erik :nick!user@host PRIVMSG #chan :hi there" into its IRCv3
+// tags, prefix, command and parameters.
+func parseLine(line string) (tags map[string]string, prefix string, command string, params []string) {
+	if strings.HasPrefix(line, "@") {
+		i := strings.Index(line, " ")
+		if i < 0 {
+			return parseTags(line[1:]), "", "", nil
+		}
+		tags, line = parseTags(line[1:i]), strings.TrimLeft(line[i+1:], " ")
+	}
+
+	if strings.HasPrefix(line, ":") {
+		i := strings.Index(line, " ")
+		if i < 0 {
+			return tags, line[1:], "", nil
+		}
+		prefix, line = line[1:i], line[i+1:]
+	}
+
+	var trailing string
+	hasTrailing := false
+	if i := strings.Index(line, " :"); i >= 0 {
+		line, trailing, hasTrailing = line[:i], line[i+2:], true
+	} else if strings.HasPrefix(line, ":") {
+		line, trailing, hasTrailing = "", line[1:], true
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		command, params = strings.ToUpper(fields[0]), fields[1:]
+	}
+	if hasTrailing {
+		params = append(params, trailing)
+	}
+	return
+}
+
+// parseTags parses tags like "account=erik;time=2020-08-01T12:00:00Z",
+// unescaping their values.
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ";") {
+		if tag == "" {
+			continue
+		}
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) == 1 {
+			tags[kv[0]] = ""
+			continue
+		}
+		tags[kv[0]] = tagValueReplacer.Replace(kv[1])
+	}
+	return tags
+}
+
+var tagValueReplacer = strings.NewReplacer(`\:`, ";", `\s`, " ", `\\`, `\`, `\r`, "\r", `\n`, "\n")
+
+func nickOf(prefix string) string {
+	if i := strings.Index(prefix, "!"); i >= 0 {
+		return prefix[:i]
+	}
+	return prefix
+}
@@ -0,0 +1,234 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		tags    map[string]string
+		prefix  string
+		command string
+		params  []string
+	}{
+		{
+			line:    "PING :irc.example.com",
+			command: "PING",
+			params:  []string{"irc.example.com"},
+		},
+		{
+			line:    ":erik!erik@example.com PRIVMSG #dev :hi there",
+			prefix:  "erik!erik@example.com",
+			command: "PRIVMSG",
+			params:  []string{"#dev", "hi there"},
+		},
+		{
+			line:    ":erik!erik@example.com PRIVMSG #dev :!so aaron :)",
+			prefix:  "erik!erik@example.com",
+			command: "PRIVMSG",
+			params:  []string{"#dev", "!so aaron :)"},
+		},
+		{
+			line:    ":erik!erik@example.com PRIVMSG #dev :",
+			prefix:  "erik!erik@example.com",
+			command: "PRIVMSG",
+			params:  []string{"#dev", ""},
+		},
+		{
+			line:    ":irc.example.com 001 erikbotdev :Welcome",
+			prefix:  "irc.example.com",
+			command: "001",
+			params:  []string{"erikbotdev", "Welcome"},
+		},
+		{
+			line:    ":aaron!a@example.com join #dev",
+			prefix:  "aaron!a@example.com",
+			command: "JOIN",
+			params:  []string{"#dev"},
+		},
+		{
+			line:    ":irc.example.com CAP * ACK :account-tag",
+			prefix:  "irc.example.com",
+			command: "CAP",
+			params:  []string{"*", "ACK", "account-tag"},
+		},
+		{
+			line:    "@account=erik :erik!erik@example.com PRIVMSG #dev :hi",
+			tags:    map[string]string{"account": "erik"},
+			prefix:  "erik!erik@example.com",
+			command: "PRIVMSG",
+			params:  []string{"#dev", "hi"},
+		},
+		{
+			line:    `@account=erik;msgid=a\sb\:c\\d;solo :erik!erik@example.com PRIVMSG #dev :hi`,
+			tags:    map[string]string{"account": "erik", "msgid": `a b;c\d`, "solo": ""},
+			prefix:  "erik!erik@example.com",
+			command: "PRIVMSG",
+			params:  []string{"#dev", "hi"},
+		},
+		{
+			line:   ":onlyprefix",
+			prefix: "onlyprefix",
+		},
+		{
+			line: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			tags, prefix, command, params := parseLine(tt.line)
+			if len(tags) != 0 || len(tt.tags) != 0 {
+				if !reflect.DeepEqual(tags, tt.tags) {
+					t.Errorf("tags %v, want %v", tags, tt.tags)
+				}
+			}
+			if prefix != tt.prefix {
+				t.Errorf("prefix %q, want %q", prefix, tt.prefix)
+			}
+			if command != tt.command {
+				t.Errorf("command %q, want %q", command, tt.command)
+			}
+			if len(params) != 0 || len(tt.params) != 0 {
+				if !reflect.DeepEqual(params, tt.params) {
+					t.Errorf("params %q, want %q", params, tt.params)
+				}
+			}
+		})
+	}
+}
+
+func TestBadges(t *testing.T) {
+	c := Config{
+		Broadcaster: "erik",
+		Moderators:  []string{"aaron", "mod!*@user/mod"},
+	}
+
+	tests := []struct {
+		name    string
+		prefix  string
+		account string
+		want    map[string]int
+	}{
+		{name: "broadcaster by account", prefix: "erik!e@example.com", account: "Erik", want: map[string]int{"broadcaster": 1}},
+		{name: "broadcaster nick without account", prefix: "erik!e@example.com", want: map[string]int{}},
+		{name: "broadcaster nick logged in as someone else", prefix: "erik!e@example.com", account: "impostor", want: map[string]int{}},
+		{name: "moderator by account", prefix: "whatever!a@example.com", account: "aaron", want: map[string]int{"moderator": 1}},
+		{name: "moderator by mask", prefix: "mod!~m@user/mod", want: map[string]int{"moderator": 1}},
+		{name: "mask ignores case", prefix: "MOD!m@USER/MOD", want: map[string]int{"moderator": 1}},
+		{name: "mask needs the host", prefix: "mod!m@example.com", want: map[string]int{}},
+		{name: "nobody", prefix: "viewer!v@example.com", account: "viewer", want: map[string]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.badges(tt.prefix, tt.account); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatUser(t *testing.T) {
+	c := Config{Broadcaster: "erik"}
+
+	tests := []struct {
+		name    string
+		prefix  string
+		account string
+		want    bot.ChatUser
+	}{
+		{
+			name:    "logged in",
+			prefix:  "Erik!e@example.com",
+			account: "Erik",
+			want:    bot.ChatUser{ID: "irc:erik", Name: "erik", DisplayName: "Erik", Badges: map[string]int{"broadcaster": 1}},
+		},
+		{
+			name:    "keyed by account not nick",
+			prefix:  "erik_away!e@example.com",
+			account: "erik",
+			want:    bot.ChatUser{ID: "irc:erik", Name: "erik_away", DisplayName: "erik_away", Badges: map[string]int{"broadcaster": 1}},
+		},
+		{
+			name:   "not logged in",
+			prefix: "Erik!e@example.com",
+			want:   bot.ChatUser{ID: "irc:guest:erik", Name: "erik", DisplayName: "Erik", Badges: map[string]int{}, Guest: true},
+		},
+		{
+			name:    "logged out",
+			prefix:  "erik!e@example.com",
+			account: "*",
+			want:    bot.ChatUser{ID: "irc:guest:erik", Name: "erik", DisplayName: "erik", Badges: map[string]int{}, Guest: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chatUser(&c, tt.prefix, tt.account); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Cancelling the context quits rather than just dropping the connection.
+func TestConnectQuitsWhenCancelled(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	old := config
+	config = Config{Server: l.Addr().String(), Nick: "bot"}
+	defer func() {
+		config = old
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	connected := make(chan error, 1)
+	go func() {
+		connected <- (&provider{}).Connect(ctx)
+	}()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var got []string
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		got = append(got, line)
+
+		// Registered, time to go
+		if strings.HasPrefix(line, "USER ") {
+			cancel()
+		}
+	}
+
+	if len(got) == 0 || got[len(got)-1] != "QUIT" {
+		t.Errorf("got %q, want it to end with QUIT", got)
+	}
+	if err := <-connected; err != nil {
+		t.Errorf("Connect returned %v", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"math"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/erikstmartin/erikbotdev/bot"
@@ -29,8 +30,28 @@ type LightOptions struct {
 	Lights []Light `json:"lights"`
 }
 
+var configLock sync.RWMutex
 var config Config
 
+func currentConfig() Config {
+	configLock.RLock()
+	defer configLock.RUnlock()
+
+	return config
+}
+
+func loadConfig(c json.RawMessage) error {
+	var newConfig Config
+	if err := json.Unmarshal(c, &newConfig); err != nil {
+		return err
+	}
+
+	configLock.Lock()
+	config = newConfig
+	configLock.Unlock()
+	return nil
+}
+
 func init() {
 	bot.RegisterModule(bot.Module{
 		Name: "keylight",
@@ -38,13 +59,25 @@ func init() {
 			"Blink":    blinkAction,
 			"Settings": settingsAction,
 		},
-		Init: func(c json.RawMessage) error {
-			return json.Unmarshal(c, &config)
+		Init:        loadConfig,
+		Reconfigure: loadConfig,
+		ActionSpecs: map[string]bot.ActionSpec{
+			"Blink": {Args: []bot.ArgSpec{
+				{Name: "count", Type: bot.ArgInt},
+				{Name: "duration", Type: bot.ArgDuration},
+				{Name: "brightness", Type: bot.ArgInt},
+				{Name: "temperature", Type: bot.ArgInt},
+			}},
+			"Settings": {Args: []bot.ArgSpec{
+				{Name: "on", Type: bot.ArgBool},
+				{Name: "brightness", Type: bot.ArgInt},
+				{Name: "temperature", Type: bot.ArgInt},
+			}},
 		},
 	})
 }
 
-func blinkAction(a bot.Action, cmd bot.Params) error {
+func blinkAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	var count int64 = 1
 	var duration = 250 * time.Millisecond
 	var err error
@@ -62,20 +95,24 @@ func blinkAction(a bot.Action, cmd bot.Params) error {
 
 	for i := 0; int64(i) < count; i++ {
 		a.Args["on"] = "false"
-		settingsAction(a, cmd)
+		settingsAction(ctx, a, cmd)
 
-		time.Sleep(duration)
+		if err := bot.Sleep(ctx, duration); err != nil {
+			return err
+		}
 
 		a.Args["on"] = "true"
-		settingsAction(a, cmd)
+		settingsAction(ctx, a, cmd)
 
-		time.Sleep(duration)
+		if err := bot.Sleep(ctx, duration); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func settingsAction(a bot.Action, cmd bot.Params) error {
+func settingsAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	var brightness int
 	var temperature int
 
@@ -95,14 +132,23 @@ func settingsAction(a bot.Action, cmd bot.Params) error {
 		temperature = convertToElgato(int(temp))
 	}
 
-	for _, addr := range config.Lights {
-		resp, err := http.Get(fmt.Sprintf("http://%s/elgato/lights", addr))
+	for _, addr := range currentConfig().Lights {
+		url := fmt.Sprintf("http://%s/elgato/lights", addr)
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return err
+		}
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
 		if err != nil {
 			return fmt.Errorf("Error fetching light info '%s': %s", addr, err)
 		}
 
 		var opt LightOptions
-		if err = json.NewDecoder(resp.Body).Decode(&opt); err != nil {
+		err = json.NewDecoder(resp.Body).Decode(&opt)
+		resp.Body.Close()
+		if err != nil {
 			return fmt.Errorf("Error unmarshalling light info '%s': %s", addr, err)
 		}
 
@@ -130,9 +176,10 @@ func settingsAction(a bot.Action, cmd bot.Params) error {
 			return err
 		}
 
-		url := fmt.Sprintf("http://%s/elgato/lights", addr)
-		req, err := http.NewRequestWithContext(context.Background(), "PUT", url, buf)
-		client := &http.Client{}
+		req, err = http.NewRequestWithContext(ctx, "PUT", url, buf)
+		if err != nil {
+			return err
+		}
 		resp, err = client.Do(req)
 		if err != nil {
 			return err
@@ -1,15 +1,18 @@
 package obs
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	obsws "github.com/christopher-dG/go-obs-websocket"
 	"github.com/erikstmartin/erikbotdev/bot"
+	"github.com/sirupsen/logrus"
 )
 
 type Config struct {
@@ -20,6 +23,9 @@ type Config struct {
 var config Config
 
 func init() {
+	// Its chatter is only useful when debugging
+	obsws.Logger = log.New(bot.Log.WriterLevel(logrus.DebugLevel), "obsws: ", 0)
+
 	bot.RegisterModule(bot.Module{
 		Name: "obs",
 		Actions: map[string]bot.ActionFunc{
@@ -27,68 +33,181 @@ func init() {
 			"ChangeScene":         changeSceneAction,
 			"StopStream":          stopStreamAction,
 		},
+		ActionSpecs: map[string]bot.ActionSpec{
+			"SourceFilterEnabled": {Args: []bot.ArgSpec{
+				{Name: "source", Required: true},
+				{Name: "filterName", Required: true},
+				{Name: "enabled", Type: bot.ArgBool, Required: true},
+			}},
+			"ChangeScene": {Args: []bot.ArgSpec{
+				{Name: "scene", Required: true},
+			}},
+			"StopStream": {},
+		},
+		Triggers: []string{
+			"obs::SceneChanged",
+			"obs::SceneChanged::*",
+		},
 		Init: func(c json.RawMessage) error {
 			if err := json.Unmarshal(c, &config); err != nil {
 				return err
 			}
-			port, err := strconv.ParseInt(config.Port, 10, 32)
-			if err != nil {
-				fmt.Printf("Failed to parse OBS_PORT: %s\n", err)
-			}
-			err = Connect(config.Host, int(port))
+			return connect(true)
+		},
+		Health: func() error {
+			c, err := currentClient()
 			if err != nil {
 				return err
 			}
+			if !c.Connected() {
+				return fmt.Errorf("Not connected to OBS")
+			}
+			return nil
+		},
+		Reconnect: func(ctx context.Context) error {
+			return connect(false)
+		},
+		Shutdown: func(ctx context.Context) error {
+			return Disconnect()
+		},
+	})
+}
+
+// connect connects to OBS and syncs the bot's status with it. Once the bot
+// is running, a stream that started while OBS was unreachable is a new
+// stream, but not when the bot itself just started.
+func connect(starting bool) error {
+	port, err := strconv.ParseInt(config.Port, 10, 32)
+	if err != nil {
+		return fmt.Errorf("obs: invalid port '%s'", config.Port)
+	}
+	err = Connect(config.Host, int(port))
+	if err != nil {
+		return err
+	}
 
-			client.AddEventHandler("SwitchScenes", func(e obsws.Event) {
-				// Make sure to assert the actual event type.
-				bot.Status.Scene = e.(obsws.SwitchScenesEvent).SceneName
-			})
+	c, err := currentClient()
+	if err != nil {
+		return err
+	}
 
-			client.AddEventHandler("StreamStatus", func(e obsws.Event) {
-				// Make sure to assert the actual event type.
-				bot.Status.Streaming = e.(obsws.StreamStatusEvent).Streaming
-			})
+	// Ensure we set the current status on the bot
+	statusReq := obsws.NewGetStreamingStatusRequest()
+	status, err := statusReq.SendReceive(*c)
+	if err != nil {
+		return err
+	}
 
-			// Ensure we set the current status on the bot
-			statusReq := obsws.NewGetStreamingStatusRequest()
-			status, err := statusReq.SendReceive(client)
-			if err != nil {
-				return err
-			}
-			bot.Status.Streaming = status.Streaming
-			log.Printf("OBS module enabled, streaming status set to %t", bot.Status.Streaming)
+	sceneReq := obsws.NewGetCurrentSceneRequest()
+	scene, err := sceneReq.SendReceive(*c)
+	if err != nil {
+		return err
+	}
 
-			sceneReq := obsws.NewGetCurrentSceneRequest()
-			scene, err := sceneReq.SendReceive(client)
-			if err != nil {
-				return err
-			}
-			bot.Status.Scene = scene.Name
+	if starting {
+		// Not SetStreaming, restarting the bot mid-stream isn't a new stream
+		bot.RestoreStatus(status.Streaming, scene.Name)
+	} else {
+		bot.SetStreaming(status.Streaming)
+		bot.SetScene(scene.Name)
+	}
+	bot.Log.WithFields(logrus.Fields{
+		"streaming": status.Streaming,
+		"scene":     scene.Name,
+	}).Info("Connected to OBS")
 
-			return nil
-		},
+	return nil
+}
+
+func init() {
+	bot.Subscribe(func(e bot.SceneChangedEvent) {
+		cmd := bot.Params{
+			Command: "obs::SceneChanged",
+			Payload: map[string]string{
+				"old": e.Old,
+				"new": e.New,
+			},
+		}
+		bot.RunTrigger("obs::SceneChanged", cmd)
+		bot.RunTrigger("obs::SceneChanged::"+e.New, cmd)
 	})
 }
 
-var client obsws.Client
+// Reconnecting swaps in a new client while actions may be using the old one
+var clientLock sync.RWMutex
+var client *obsws.Client
+
+func currentClient() (*obsws.Client, error) {
+	clientLock.RLock()
+	defer clientLock.RUnlock()
 
+	if client == nil {
+		return nil, fmt.Errorf("Not connected to OBS")
+	}
+	return client, nil
+}
+
+// Connect connects a new client to OBS, replacing and disconnecting the
+// current one.
 func Connect(host string, port int) error {
-	client = obsws.Client{Host: host, Port: port}
-	if err := client.Connect(); err != nil {
+	c := &obsws.Client{Host: host, Port: port}
+	if err := c.Connect(); err != nil {
 		return err
 	}
 	obsws.SetReceiveTimeout(time.Second * 2)
+
+	c.AddEventHandler("SwitchScenes", func(e obsws.Event) {
+		// Make sure to assert the actual event type.
+		bot.SetScene(e.(obsws.SwitchScenesEvent).SceneName)
+	})
+
+	c.AddEventHandler("StreamStatus", func(e obsws.Event) {
+		// Make sure to assert the actual event type.
+		bot.SetStreaming(e.(obsws.StreamStatusEvent).Streaming)
+	})
+
+	c.AddEventHandler("StreamStarted", func(e obsws.Event) {
+		bot.SetStreaming(true)
+	})
+
+	// StreamStatus events stop with the stream, so they never report it ending
+	c.AddEventHandler("StreamStopped", func(e obsws.Event) {
+		bot.SetStreaming(false)
+	})
+
+	clientLock.Lock()
+	old := client
+	client = c
+	clientLock.Unlock()
+
+	if old != nil && old.Connected() {
+		if err := old.Disconnect(); err != nil {
+			bot.Log.WithError(err).Debug("Failed to disconnect old OBS client")
+		}
+	}
 	return nil
 }
 
 func Disconnect() error {
-	return client.Disconnect()
+	clientLock.Lock()
+	c := client
+	client = nil
+	clientLock.Unlock()
+
+	if c == nil || !c.Connected() {
+		return nil
+	}
+	return c.Disconnect()
 }
 
 func Streaming() (bool, error) {
+	c, err := currentClient()
+	if err != nil {
+		return false, err
+	}
+
 	statusReq := obsws.NewGetStreamingStatusRequest()
-	status, err := statusReq.SendReceive(client)
+	status, err := statusReq.SendReceive(*c)
 	if err != nil {
 		return false, err
 	}
@@ -96,8 +215,13 @@ func Streaming() (bool, error) {
 }
 
 func StopStream() error {
+	c, err := currentClient()
+	if err != nil {
+		return err
+	}
+
 	statusReq := obsws.NewGetStreamingStatusRequest()
-	status, err := statusReq.SendReceive(client)
+	status, err := statusReq.SendReceive(*c)
 	if err != nil {
 		return err
 	}
@@ -107,24 +231,29 @@ func StopStream() error {
 	}
 
 	req := obsws.NewStartStopStreamingRequest()
-	_, err = req.SendReceive(client)
+	_, err = req.SendReceive(*c)
 	return err
 }
 
-func stopStreamAction(a bot.Action, cmd bot.Params) error {
+func stopStreamAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	return StopStream()
 }
 
 func EnableSourceFilter(sourceName string, filterName string, enabled bool) error {
+	c, err := currentClient()
+	if err != nil {
+		return err
+	}
+
 	req := obsws.NewSetSourceFilterVisibilityRequest(sourceName, filterName, enabled)
-	if _, err := req.SendReceive(client); err != nil {
+	if _, err := req.SendReceive(*c); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func enableSourceFilterAction(a bot.Action, cmd bot.Params) error {
+func enableSourceFilterAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	if _, ok := a.Args["source"]; !ok {
 		return fmt.Errorf("Argument 'source' is required.")
 	}
@@ -145,14 +274,19 @@ func enableSourceFilterAction(a bot.Action, cmd bot.Params) error {
 }
 
 func ChangeScene(scene string) error {
+	c, err := currentClient()
+	if err != nil {
+		return err
+	}
+
 	req := obsws.NewSetCurrentSceneRequest(scene)
-	if _, err := req.SendReceive(client); err != nil {
+	if _, err := req.SendReceive(*c); err != nil {
 		return err
 	}
 	return nil
 }
 
-func changeSceneAction(a bot.Action, cmd bot.Params) error {
+func changeSceneAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
 	if _, ok := a.Args["scene"]; !ok {
 		return fmt.Errorf("Argument 'scene' is required.")
 	}
@@ -0,0 +1,59 @@
+package obs
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+)
+
+// The "name" arg of every obstest::Record action run
+var ranLock sync.Mutex
+var ran []string
+
+func init() {
+	bot.RegisterModule(bot.Module{
+		Name: "obstest",
+		Actions: map[string]bot.ActionFunc{
+			"Record": func(ctx context.Context, a bot.Action, cmd bot.Params) error {
+				ranLock.Lock()
+				defer ranLock.Unlock()
+
+				ran = append(ran, a.Args["name"])
+				return nil
+			},
+		},
+	})
+}
+
+func TestSceneChangedTriggers(t *testing.T) {
+	ranLock.Lock()
+	ran = nil
+	ranLock.Unlock()
+
+	err := bot.LoadConfig(strings.NewReader(`{"enabledModules": ["obstest"], "triggers": {
+		"obs::SceneChanged": {"actions": [{"name": "obstest::Record", "args": {"name": "{{payload.old}} -> {{payload.new}}"}}]},
+		"obs::SceneChanged::Coding": {"actions": [{"name": "obstest::Record", "args": {"name": "coding"}}]}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot.RestoreStatus(false, "Starting")
+	bot.SetScene("Coding")
+	bot.SetScene("Ending")
+	bot.WaitForCommands(time.Second)
+
+	// The triggers run on their own goroutines
+	ranLock.Lock()
+	defer ranLock.Unlock()
+	sort.Strings(ran)
+	want := []string{"Coding -> Ending", "Starting -> Coding", "coding"}
+	if strings.Join(ran, "|") != strings.Join(want, "|") {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+}
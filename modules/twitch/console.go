@@ -0,0 +1,48 @@
+package twitch
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+)
+
+// ConsoleUser is the fake chatter that console messages come from.
+type ConsoleUser struct {
+	ID     string
+	Name   string
+	Badges map[string]int
+}
+
+var consoleOut io.Writer
+
+// RunConsole reads chat messages from r, one per line, and handles them as if
+// user had sent them to the main channel. Everything the bot says goes to w
+// instead of Twitch. It returns once r is exhausted.
+func RunConsole(r io.Reader, w io.Writer, user ConsoleUser) error {
+	consoleOut = w
+	channel := currentConfig().MainChannel
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		bot.HandleChatMessage(bot.ChatMessage{
+			Provider: "twitch",
+			Channel:  channel,
+			User: bot.ChatUser{
+				ID:          user.ID,
+				Name:        user.Name,
+				DisplayName: user.Name,
+				Badges:      user.Badges,
+			},
+			Text:        line,
+			MainChannel: true,
+		})
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,53 @@
+package twitch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+)
+
+func TestRunConsole(t *testing.T) {
+	dir, err := ioutil.TempDir("", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := bot.OpenDatabase(filepath.Join(dir, "bot.db"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.CloseDatabase()
+
+	err = bot.LoadConfig(strings.NewReader(`{
+		"enabledModules": ["twitch"],
+		"moduleConfig": {"twitch": {"mainChannel": "erikdotdev"}},
+		"commands": {"hi": {"enabled": true, "offline": true, "actions": [{"name": "twitch::Say", "args": {"message": "hi {{user}}, {{args}}"}}]}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.InitModules()
+	defer func() {
+		consoleOut = nil
+	}()
+
+	var out bytes.Buffer
+	in := strings.NewReader("just chatting\n\n!hi how are you\n")
+	if err := RunConsole(in, &out, ConsoleUser{ID: "1", Name: "erik", Badges: map[string]int{"broadcaster": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if !bot.WaitForCommands(time.Second) {
+		t.Fatal("the command didn't finish")
+	}
+
+	want := "[#erikdotdev] hi erik, how are you\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
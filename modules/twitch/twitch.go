@@ -1,16 +1,17 @@
 package twitch
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/erikstmartin/erikbotdev/bot"
-	"github.com/erikstmartin/erikbotdev/http"
 	"github.com/gempir/go-twitch-irc/v2"
 	"github.com/nicklaw5/helix"
+	"github.com/sirupsen/logrus"
 	"github.com/xeonx/timeago"
 )
 
@@ -23,61 +24,126 @@ type Config struct {
 	IgnoredUsers []string `json:"ignoredUsers"`
 }
 
-func (c *Config) GetClientID() string {
-	if strings.HasPrefix(c.ClientID, "$") {
-		return os.Getenv(strings.TrimPrefix(c.ClientID, "$"))
+func (c *Config) isIgnoredUser(username string) bool {
+	for _, name := range c.IgnoredUsers {
+		if strings.ToLower(name) == strings.ToLower(username) {
+			return true
+		}
 	}
-	return c.ClientID
+
+	return false
 }
 
-func (c *Config) GetClientSecret() string {
-	if strings.HasPrefix(c.ClientSecret, "$") {
-		return os.Getenv(strings.TrimPrefix(c.ClientSecret, "$"))
-	}
-	return c.ClientSecret
+var client *twitch.Client
+var configLock sync.RWMutex
+var config Config
+
+func currentConfig() Config {
+	configLock.RLock()
+	defer configLock.RUnlock()
+
+	return config
 }
 
-func (c *Config) GetOauthToken() string {
-	if strings.HasPrefix(c.OauthToken, "$") {
-		return os.Getenv(strings.TrimPrefix(c.OauthToken, "$"))
+// reconfigure applies a new config to the running client. Channels are joined
+// and left as needed, changes to the main channel or credentials still need a
+// restart.
+func reconfigure(c json.RawMessage) error {
+	var newConfig Config
+	if err := json.Unmarshal(c, &newConfig); err != nil {
+		return err
+	}
+
+	configLock.Lock()
+	old := config
+	config = newConfig
+	configLock.Unlock()
+
+	if client == nil {
+		return nil
+	}
+
+	if old.MainChannel != newConfig.MainChannel || old.OauthToken != newConfig.OauthToken {
+		bot.Log.Warn("Twitch main channel or credentials changed, restart the bot to apply them")
 	}
-	return c.OauthToken
+
+	for _, ch := range newConfig.Channels {
+		if !contains(old.Channels, ch) {
+			client.Join(ch)
+		}
+	}
+	for _, ch := range old.Channels {
+		if !contains(newConfig.Channels, ch) {
+			client.Depart(ch)
+		}
+	}
+	return nil
 }
 
-func (c *Config) isIgnoredUser(username string) bool {
-	for _, name := range c.IgnoredUsers {
-		if strings.ToLower(name) == strings.ToLower(username) {
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
 			return true
 		}
 	}
-
 	return false
 }
 
-var client *twitch.Client
-var config Config
-
 func init() {
+	bot.RegisterChatProvider(&provider{})
 	bot.RegisterModule(bot.Module{
 		Name: "twitch",
 		Actions: map[string]bot.ActionFunc{
 			"Say":    sayAction,
 			"Uptime": uptimeAction,
 		},
+		ActionSpecs: map[string]bot.ActionSpec{
+			"Say": {Args: []bot.ArgSpec{
+				{Name: "message", Required: true},
+				{Name: "channel"},
+			}},
+			"Uptime": {Args: []bot.ArgSpec{
+				{Name: "channel"},
+			}},
+		},
+		// https://dev.twitch.tv/docs/irc/tags#usernotice-twitch-tags
+		Triggers: []string{
+			"twitch::Chat",
+			"twitch::Follow",
+			"twitch::sub",
+			"twitch::resub",
+			"twitch::subgift",
+			"twitch::anonsubgift",
+			"twitch::submysterygift",
+			"twitch::giftpaidupgrade",
+			"twitch::rewardgift",
+			"twitch::anongiftpaidupgrade",
+			"twitch::raid",
+			"twitch::unraid",
+			"twitch::ritual",
+			"twitch::bitsbadgetier",
+		},
 		Init: func(c json.RawMessage) error {
 			return json.Unmarshal(c, &config)
 		},
+		Reconfigure: reconfigure,
 	})
 }
 
-func uptimeAction(a bot.Action, cmd bot.Params) error {
-	var channel = cmd.Channel
+func uptimeAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
+	config := currentConfig()
+	channel := replyChannel(config, cmd)
 
 	if _, ok := a.Args["channel"]; ok {
 		channel = a.Args["channel"]
 	}
 
-	streamResp, err := bot.GetHelixClient().GetStreams(&helix.StreamsParams{
+	api := bot.GetHelixClient()
+	if api == nil {
+		return fmt.Errorf("Twitch API is not available")
+	}
+
+	streamResp, err := api.GetStreams(&helix.StreamsParams{
 		UserLogins: []string{config.MainChannel},
 	})
 	if err != nil {
@@ -94,18 +160,17 @@ func uptimeAction(a bot.Action, cmd bot.Params) error {
 
 	startedAt := streams[0].StartedAt.Truncate(time.Minute)
 	uptime := timeago.NoMax(timeago.English).Format(startedAt)
-	client.Say(
+	return say(
 		channel,
 		fmt.Sprintf(
 			"I started streaming %s",
 			uptime,
 		),
 	)
-	return nil
 }
 
-func sayAction(a bot.Action, cmd bot.Params) error {
-	var channel = cmd.Channel
+func sayAction(ctx context.Context, a bot.Action, cmd bot.Params) error {
+	channel := replyChannel(currentConfig(), cmd)
 
 	if _, ok := a.Args["channel"]; ok {
 		channel = a.Args["channel"]
@@ -114,69 +179,88 @@ func sayAction(a bot.Action, cmd bot.Params) error {
 	if _, ok := a.Args["message"]; !ok {
 		return fmt.Errorf("Argument 'message' is required.")
 	}
-	client.Say(channel, a.Args["message"])
+	return say(channel, a.Args["message"])
+}
+
+// replyChannel returns the channel cmd came from if it came from Twitch, the
+// main channel otherwise.
+func replyChannel(c Config, cmd bot.Params) string {
+	if cmd.Provider == "twitch" {
+		return cmd.Channel
+	}
+	return c.MainChannel
+}
+
+// say sends a message to chat, or to the console when running in console
+// mode.
+func say(channel string, message string) error {
+	if consoleOut != nil {
+		fmt.Fprintf(consoleOut, "[#%s] %s\n", channel, message)
+		return nil
+	}
+	if client == nil {
+		return fmt.Errorf("Not connected to Twitch")
+	}
+
+	client.Say(channel, message)
 	return nil
 }
 
-func Run() error {
-	client = twitch.NewClient(config.MainChannel, config.GetOauthToken())
+// provider is the bot.ChatProvider for Twitch chat.
+type provider struct {
+	onMessage func(bot.ChatMessage)
+	onEvent   func(bot.ChatEvent)
+}
 
-	client.OnConnect(func() {
-		fmt.Println("Connected!")
-	})
+func (p *provider) Name() string {
+	return "twitch"
+}
 
-	client.OnPrivateMessage(func(message twitch.PrivateMessage) {
-		var u *bot.User
-		var err error
+func (p *provider) Say(channel string, message string) error {
+	return say(channel, message)
+}
 
-		u, err = bot.GetUser(message.User.ID)
-		if err != nil {
-			fmt.Println("Error retrieving user: ", err)
-			return
-		}
+func (p *provider) MainChannel() string {
+	return currentConfig().MainChannel
+}
 
-		u.DisplayName = message.User.DisplayName
-		u.Color = message.User.Color
-		u.Badges = message.User.Badges
+func (p *provider) OnMessage(f func(bot.ChatMessage)) {
+	p.onMessage = f
+}
 
-		if u.New {
-			u.ID = message.User.ID
-			u.Points = 2500
-			if err := u.Save(); err != nil {
-				return
-			}
-		}
+func (p *provider) OnEvent(f func(bot.ChatEvent)) {
+	p.onEvent = f
+}
 
-		if !strings.HasPrefix(message.Message, "!") && len(message.Message) >= 1 && !config.isIgnoredUser(u.DisplayName) {
-			u.GivePoints(10)
-
-			if message.Channel == config.MainChannel {
-				bot.ExecuteTrigger("twitch::Chat", bot.Params{
-					UserID:   u.ID,
-					UserName: u.DisplayName,
-					Channel:  message.Channel,
-				})
-				http.BroadcastChatMessage(u, message.Message)
-			}
-			return
-		}
+func chatUser(u twitch.User) bot.ChatUser {
+	return bot.ChatUser{
+		ID:          u.ID,
+		Name:        u.Name,
+		DisplayName: u.DisplayName,
+		Color:       u.Color,
+		Badges:      u.Badges,
+	}
+}
 
-		if message.Channel == config.MainChannel {
-			parts := strings.Fields(message.Message[1:])
-			cmdName := strings.ToLower(parts[0])
-			cmd := bot.Params{
-				Channel:     message.Channel,
-				UserID:      message.User.ID,
-				UserName:    message.User.DisplayName,
-				UserBadges:  message.User.Badges,
-				Command:     cmdName,
-				CommandArgs: parts[1:],
-			}
-			err = bot.ExecuteCommand(cmd)
-			if err != nil {
-				fmt.Println("Error executing command: ", err)
-			}
-		}
+func (p *provider) Connect(ctx context.Context) error {
+	config := currentConfig()
+	client = twitch.NewClient(config.MainChannel, config.OauthToken)
+
+	client.OnConnect(func() {
+		// Also called after go-twitch-irc reconnects
+		bot.Log.WithField("channel", config.MainChannel).Info("Connected to Twitch chat")
+	})
+
+	client.OnPrivateMessage(func(message twitch.PrivateMessage) {
+		config := currentConfig()
+		p.onMessage(bot.ChatMessage{
+			Provider:    "twitch",
+			Channel:     message.Channel,
+			User:        chatUser(message.User),
+			Text:        message.Message,
+			MainChannel: message.Channel == config.MainChannel,
+			Ignored:     config.isIgnoredUser(message.User.DisplayName),
+		})
 	})
 
 	//TODO: Respond to Twitch events
@@ -184,14 +268,18 @@ func Run() error {
 
 	client.OnUserNoticeMessage(func(message twitch.UserNoticeMessage) {
 		// TODO: Leave this here, till we've implement all notice messages
-		b, _ := json.Marshal(message)
-		fmt.Println("UserNoticeMessage", string(b))
+		bot.Log.WithFields(logrus.Fields{
+			"msgID": message.MsgID,
+			"user":  message.User.DisplayName,
+			"tags":  message.Tags,
+		}).Debug("Twitch user notice")
 
 		// TODO: Document all possible triggers
-		bot.ExecuteTrigger(fmt.Sprintf("twitch::%s", message.MsgID), bot.Params{
-			UserID:   message.User.ID,
-			UserName: message.User.DisplayName,
+		p.onEvent(bot.ChatEvent{
+			Provider: "twitch",
 			Channel:  message.Channel,
+			User:     chatUser(message.User),
+			Name:     message.MsgID,
 			Payload:  message.Tags,
 		})
 
@@ -223,5 +311,13 @@ func Run() error {
 
 	client.Join(config.Channels...)
 
-	return client.Connect()
+	go func() {
+		<-ctx.Done()
+		client.Disconnect()
+	}()
+
+	if err := client.Connect(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,29 @@
+package twitch
+
+import (
+	"testing"
+
+	"github.com/erikstmartin/erikbotdev/bot"
+)
+
+func TestReplyChannel(t *testing.T) {
+	c := Config{MainChannel: "erikdotdev"}
+
+	tests := []struct {
+		name string
+		cmd  bot.Params
+		want string
+	}{
+		{name: "twitch", cmd: bot.Params{Provider: "twitch", Channel: "aaronbot5000"}, want: "aaronbot5000"},
+		{name: "irc", cmd: bot.Params{Provider: "irc", Channel: "#dev"}, want: "erikdotdev"},
+		{name: "timer", cmd: bot.Params{Channel: "erikdotdev"}, want: "erikdotdev"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replyChannel(c, tt.cmd); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}